@@ -10,13 +10,35 @@ chrome.runtime.onMessage.addListener((request, sender, sendResponse) => {
   }
 });
 
-// 处理打开 IDE 请求
+// native messaging host 名称，需先运行 github-browser-service native-host install
+const NATIVE_HOST = 'com.github_browser.service';
+
+// 通过 native messaging 调用服务，host 未安装时返回 null
+async function callNativeHost(action, params) {
+  let response;
+  try {
+    response = await chrome.runtime.sendNativeMessage(NATIVE_HOST, { action, params });
+  } catch (e) {
+    return null;
+  }
+  if (!response.ok) {
+    throw new Error((response.result && response.result.message) || response.error || 'Failed to open repository');
+  }
+  return response.result;
+}
+
+// 处理打开 IDE 请求，优先使用 native messaging，不可用时回退到 HTTP
 async function handleOpenInIDE(url) {
   const config = await chrome.storage.sync.get({
     serviceUrl: 'http://localhost:9527',
     ide: 'code'
   });
 
+  const result = await callNativeHost('open', { url: url, ide: config.ide });
+  if (result) {
+    return result;
+  }
+
   let response;
   try {
     response = await fetch(`${config.serviceUrl}/open`, {
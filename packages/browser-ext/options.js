@@ -80,15 +80,11 @@ async function saveSettings(e) {
     // 同步路径映射配置到服务端
     if (pathMappings.length > 0) {
       try {
-        // 先获取当前服务端配置
-        const configRes = await fetch(`${serviceUrl}/config`);
-        const currentConfig = await configRes.json();
-        
-        // 更新 pathMappings
+        // 只更新 pathMappings，其他配置保持不变
         await fetch(`${serviceUrl}/config`, {
-          method: 'PUT',
+          method: 'PATCH',
           headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify({ ...currentConfig, pathMappings: pathMappings })
+          body: JSON.stringify({ pathMappings: pathMappings })
         });
       } catch (e) {
         // 忽略同步失败
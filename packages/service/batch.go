@@ -0,0 +1,387 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	multiRootWorkspace = "workspace" // 生成 .code-workspace 文件
+	multiRootArgs      = "args"      // 在一条命令中传入多个路径
+)
+
+// WorkspaceSet 是在配置中保存的一组仓库，可通过名称一次打开
+type WorkspaceSet struct {
+	URLs []string `json:"urls"`
+	IDE  string   `json:"ide,omitempty"` // 为空时使用 defaultIDE
+}
+
+// validate 检查工作区集合是否有效
+func (w WorkspaceSet) validate(name string, customIDEs map[string]CustomIDE) error {
+	// 名称会用作 .code-workspace 的文件名
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid workspace name %q", name)
+	}
+	if len(w.URLs) == 0 {
+		return fmt.Errorf("urls is required")
+	}
+	for i, u := range w.URLs {
+		if _, err := ParseGitHubURL(u); err != nil {
+			return fmt.Errorf("urls[%d]: %v", i, err)
+		}
+	}
+	if w.IDE != "" {
+		if _, ok := lookupIDE(w.IDE, customIDEs); !ok {
+			return fmt.Errorf("unknown IDE %s", w.IDE)
+		}
+	}
+	return nil
+}
+
+type BatchOpenRequest struct {
+	URLs      []string `json:"urls"`
+	Workspace string   `json:"workspace"` // 配置中 workspaces 的名称，与 urls 二选一
+	IDE       string   `json:"ide"`
+}
+
+// BatchRepoResult 是批量打开中单个仓库的准备结果
+type BatchRepoResult struct {
+	URL     string           `json:"url"`
+	Status  string           `json:"status"`
+	Message string           `json:"message,omitempty"`
+	Code    ErrorCode        `json:"code,omitempty"`
+	Path    string           `json:"path,omitempty"`
+	Skipped []string         `json:"skipped,omitempty"`
+	Hooks   []HookResult     `json:"hooks,omitempty"`
+	Timings map[string]int64 `json:"timings,omitempty"` // 准备该仓库各阶段的耗时（毫秒）
+}
+
+type BatchOpenResponse struct {
+	Status        string            `json:"status"` // ok、partial（部分仓库失败）或 error
+	Message       string            `json:"message"`
+	Code          ErrorCode         `json:"code,omitempty"`
+	Hint          string            `json:"hint,omitempty"`
+	Repos         []BatchRepoResult `json:"repos"`
+	IDE           string            `json:"ide,omitempty"`
+	FallbackFrom  string            `json:"fallbackFrom,omitempty"`
+	WorkspaceFile string            `json:"workspaceFile,omitempty"` // 生成的 .code-workspace 文件
+	Launches      []*LaunchResult   `json:"launches,omitempty"`
+	RequestID     string            `json:"requestId,omitempty"`
+}
+
+// batchItem 是一个待准备的仓库
+type batchItem struct {
+	index  int
+	info   *GitHubURLInfo
+	target OpenTarget
+}
+
+func (s *Service) handleOpenBatch(c *gin.Context) {
+	var req BatchOpenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBatchError(c, nil, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid request: %v", err), err))
+		return
+	}
+
+	urls, ideName, name := req.URLs, req.IDE, req.Workspace
+	if name != "" {
+		set, ok := s.config.Workspaces[name]
+		if !ok {
+			respondBatchError(c, nil, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("unknown workspace: %s", name), nil))
+			return
+		}
+		urls = set.URLs
+		if ideName == "" {
+			ideName = set.IDE
+		}
+	}
+	if len(urls) == 0 {
+		respondBatchError(c, nil, newServiceError(ErrCodeInvalidRequest, "urls or workspace is required", nil))
+		return
+	}
+	if ideName == "" {
+		ideName = s.config.DefaultIDE
+	}
+
+	s.log.Info("Received batch open request", "repos", len(urls))
+
+	start := time.Now()
+	results := make([]BatchRepoResult, len(urls))
+	infos := make([]*GitHubURLInfo, len(urls))
+	var items []*batchItem
+	for i, u := range urls {
+		results[i] = BatchRepoResult{URL: u}
+		info, err := ParseGitHubURL(u)
+		if err != nil {
+			results[i].fail(fmt.Errorf("Invalid GitHub URL: %w", err))
+			continue
+		}
+		infos[i] = info
+		items = append(items, &batchItem{index: i, info: info})
+	}
+	chosen := ideName
+	defer func() { s.recordBatchHistory(c, results, infos, chosen, start) }()
+
+	s.prepareBatch(items, results)
+
+	var targets []OpenTarget
+	for _, item := range items {
+		if results[item.index].Status == "ok" {
+			targets = append(targets, item.target)
+		}
+	}
+	if len(targets) == 0 {
+		// 所有仓库都失败时使用第一个失败的错误码
+		code := ErrCodeGitFailed
+		if results[0].Code != "" {
+			code = results[0].Code
+		}
+		respondBatchError(c, results, newServiceError(code, "no repository could be prepared", nil))
+		return
+	}
+
+	var fallbackFrom string
+	chosen, fallbackFrom = chooseIDE(ideName, s.config.IDEPreference, s.config.CustomIDEs)
+	if fallbackFrom != "" {
+		s.log.Warn("IDE is not installed, falling back", "ide", fallbackFrom, "fallback", chosen)
+	}
+	if name == "" {
+		name = workspaceName(targets)
+	}
+
+	if err := checkStopping(); err != nil {
+		respondBatchError(c, results, err)
+		return
+	}
+	s.log.Info("Opening repositories in IDE", "ide", chosen, "repos", len(targets))
+	workspaceFile, launches, err := s.openTogether(chosen, name, targets)
+	if err != nil {
+		respondBatchError(c, results, fmt.Errorf("Failed to open IDE: %w", err))
+		return
+	}
+
+	status, message := "ok", "Opened successfully"
+	if len(targets) < len(urls) {
+		status = "partial"
+		message = fmt.Sprintf("Opened %d of %d repositories", len(targets), len(urls))
+	}
+	c.JSON(200, BatchOpenResponse{
+		Status:        status,
+		Message:       message,
+		Repos:         results,
+		IDE:           chosen,
+		FallbackFrom:  fallbackFrom,
+		WorkspaceFile: workspaceFile,
+		Launches:      launches,
+		RequestID:     requestID(c),
+	})
+}
+
+// prepareBatch 并行克隆/更新各仓库，指向同一本地路径的 URL 在同一个 goroutine 中依次处理
+// 同一路径只能 checkout 一个 ref，被后面的 URL 切换掉的 URL 会以 invalid_request 失败
+func (s *Service) prepareBatch(items []*batchItem, results []BatchRepoResult) {
+	groups := make(map[string][]*batchItem)
+	for _, item := range items {
+		path := s.paths.resolve(item.info).Path
+		groups[path] = append(groups[path], item)
+	}
+
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Add(1)
+		go func(group []*batchItem) {
+			defer wg.Done()
+			heads := make(map[*batchItem]string)
+			for _, item := range group {
+				result := &results[item.index]
+				timer := newStageTimer()
+				host := s.localHost(item.info)
+				repoPath, stages, err := s.prepareRepository(item.info, host, timer)
+				result.Timings = timer.milliseconds()
+				if err != nil {
+					s.log.Error("Preparing repository failed", "owner", item.info.Owner, "repo", item.info.Repo, "error", err)
+					result.fail(err)
+					continue
+				}
+				// 没有指定 ref 的 URL 也记录 HEAD，之后被其他 URL 切换时同样失败
+				_, heads[item], _ = host.git.ResolveRef(repoPath, "HEAD")
+				item.target = openTarget(&OpenRequest{}, item.info, repoPath)
+				result.Status, result.Path = "ok", repoPath
+				result.Skipped = s.prepareWorkingTree(item.info, repoPath, item.target.Path, timer)
+				timer.time(StageHooks, func() error {
+					result.Hooks = s.runHooks(item.info, repoPath, append(stages, HookBeforeLaunch))
+					return nil
+				})
+				result.Timings = timer.milliseconds()
+			}
+			s.failSupersededRefs(group, heads, results)
+		}(group)
+	}
+	wg.Wait()
+}
+
+// failSupersededRefs 将 checkout 之后又被同一路径上的其他 URL 切换掉的 URL 标记为失败
+// 工作区中只保留最后一次 checkout 的 ref，打开它们会显示错误的代码
+func (s *Service) failSupersededRefs(group []*batchItem, heads map[*batchItem]string, results []BatchRepoResult) {
+	var final *batchItem
+	for _, item := range group {
+		if heads[item] != "" {
+			final = item
+		}
+	}
+	if final == nil {
+		return
+	}
+	for _, item := range group {
+		result := &results[item.index]
+		if item == final || heads[item] == "" || heads[item] == heads[final] || result.Status != "ok" {
+			continue
+		}
+		s.log.Warn("Repository path is checked out at another ref", "url", result.URL, "path", result.Path, "by", results[final.index].URL)
+		result.fail(newServiceError(ErrCodeInvalidRequest,
+			fmt.Sprintf("%s uses the same local path as %s but a different ref; open them separately or map them to different paths", result.URL, results[final.index].URL), nil))
+	}
+}
+
+// recordBatchHistory 把批量打开中的每个仓库作为一条记录写入打开历史
+func (s *Service) recordBatchHistory(c *gin.Context, results []BatchRepoResult, infos []*GitHubURLInfo, ide string, start time.Time) {
+	elapsed := time.Since(start).Milliseconds()
+	entries := make([]*HistoryEntry, 0, len(results))
+	for i, r := range results {
+		entry := newHistoryEntry(c, r.URL, infos[i])
+		entry.IDE, entry.Path, entry.Batch, entry.DurationMs = ide, r.Path, true, elapsed
+		entry.Status, entry.Code, entry.Message = r.Status, r.Code, r.Message
+		// 仓库准备成功但启动 IDE 失败时，使用整个请求的错误
+		if code := c.GetString(errorCodeKey); r.Status == "ok" && code != "" {
+			entry.Status, entry.Code, entry.Message = "error", ErrorCode(code), c.GetString(errorMessageKey)
+		}
+		entries = append(entries, entry)
+	}
+	s.recordHistory(entries...)
+}
+
+// fail 记录仓库准备失败的原因
+func (r *BatchRepoResult) fail(err error) {
+	se := asServiceError(err)
+	r.Status, r.Message, r.Code = "error", se.Message, se.Code
+}
+
+// openTogether 在一个 IDE 中打开多个仓库
+// VS Code 系列生成 .code-workspace，Zed 在一条命令中传入所有路径，其他 IDE 逐个打开
+func (s *Service) openTogether(ideName, name string, targets []OpenTarget) (string, []*LaunchResult, error) {
+	ide, ok := lookupIDE(ideName, s.config.CustomIDEs)
+	if !ok {
+		return "", nil, newServiceError(ErrCodeUnsupportedIDE, fmt.Sprintf("unsupported IDE: %s", ideName), nil)
+	}
+
+	var workspaceFile string
+	var args []string
+	switch ide.multiRoot {
+	case multiRootWorkspace:
+		var err error
+		if workspaceFile, err = writeCodeWorkspace(name, targets); err != nil {
+			return "", nil, err
+		}
+		args = []string{"--new-window", workspaceFile}
+	case multiRootArgs:
+		for _, t := range targets {
+			args = append(args, t.RepoPath)
+		}
+	default:
+		var launches []*LaunchResult
+		for _, t := range targets {
+			launch, err := OpenInIDE(ideName, t, s.config)
+			if err != nil {
+				return "", launches, err
+			}
+			launches = append(launches, launch)
+		}
+		return "", launches, nil
+	}
+
+	cmd := exec.Command(resolveIDECommand(ide.cmd), args...)
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", nil, newServiceError(ErrCodeIDENotInstalled, fmt.Sprintf("IDE command not found: %s", cmd.Args[0]), err)
+		}
+		return "", nil, err
+	}
+	return workspaceFile, []*LaunchResult{{Mode: LaunchNew}}, nil
+}
+
+// codeWorkspace 是 VS Code .code-workspace 文件的结构
+type codeWorkspace struct {
+	Folders  []codeWorkspaceFolder `json:"folders"`
+	Settings map[string]any        `json:"settings"`
+}
+
+type codeWorkspaceFolder struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// writeCodeWorkspace 在 ~/.github-browser/workspaces 下生成 .code-workspace 文件
+// 同名文件会被覆盖，同一仓库只出现一次
+func writeCodeWorkspace(name string, targets []OpenTarget) (string, error) {
+	ws := codeWorkspace{Settings: map[string]any{}}
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		if seen[t.RepoPath] {
+			continue
+		}
+		seen[t.RepoPath] = true
+		ws.Folders = append(ws.Folders, codeWorkspaceFolder{Name: filepath.Base(t.RepoPath), Path: t.RepoPath})
+	}
+
+	dir := filepath.Join(os.Getenv("HOME"), ".github-browser", "workspaces")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(ws, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name+".code-workspace")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// workspaceName 为未命名的批量请求生成稳定的文件名，相同的仓库组合得到相同的名称
+func workspaceName(targets []OpenTarget) string {
+	paths := make([]string, 0, len(targets))
+	for _, t := range targets {
+		paths = append(paths, t.RepoPath)
+	}
+	sort.Strings(paths)
+	sum := sha1.Sum([]byte(strings.Join(paths, "\n")))
+	return "batch-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// respondBatchError 返回批量请求的错误，results 为已有的各仓库结果
+func respondBatchError(c *gin.Context, results []BatchRepoResult, err error) {
+	se := asServiceError(err)
+	c.Set(errorCodeKey, string(se.Code))
+	c.Set(errorMessageKey, se.Message)
+	c.JSON(se.Code.HTTPStatus(), BatchOpenResponse{
+		Status:    "error",
+		Message:   se.Message,
+		Code:      se.Code,
+		Hint:      se.Hint,
+		Repos:     results,
+		RequestID: requestID(c),
+	})
+}
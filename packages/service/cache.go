@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheEntry 描述缓存目录中的一个仓库
+type CacheEntry struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	LastUsed time.Time `json:"lastUsed"`       // 最近一次 checkout 或 fetch 的时间
+	Size     int64     `json:"size,omitempty"` // 占用的字节数
+}
+
+type PruneCacheRequest struct {
+	OlderThan string `json:"olderThan" binding:"required"` // 如 "720h" 或 "30d"
+	DryRun    bool   `json:"dryRun"`
+	Force     bool   `json:"force"` // 同时删除有未提交修改或未推送提交的仓库
+}
+
+// SkippedCacheEntry 是因有本地修改而没有删除的缓存仓库
+type SkippedCacheEntry struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"` // 如 "uncommitted changes"、"3 unpushed commits"
+}
+
+// CacheSizeResponse 是 GET /cache/size 的响应，仓库按大小从大到小排列
+type CacheSizeResponse struct {
+	Repos []CacheEntry `json:"repos"`
+	Count int          `json:"count"`
+	Total int64        `json:"total"` // 所有仓库占用的字节数
+}
+
+// PruneCacheResponse 是 POST /cache/prune 的响应
+type PruneCacheResponse struct {
+	Status  string              `json:"status"`
+	DryRun  bool                `json:"dryRun"`
+	Removed []CacheEntry        `json:"removed"` // 删除的仓库，dryRun 时为将要删除的仓库
+	Skipped []SkippedCacheEntry `json:"skipped"` // 有本地修改而保留的仓库，force 时为空
+	Freed   int64               `json:"freed"`   // 释放的字节数
+}
+
+// handleCacheSize 返回每个缓存仓库及缓存目录的总大小
+func (s *Service) handleCacheSize(c *gin.Context) {
+	entries, err := s.cacheEntries(true)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Size > entries[j].Size })
+
+	c.JSON(200, CacheSizeResponse{
+		Repos: entries,
+		Count: len(entries),
+		Total: total,
+	})
+}
+
+// handlePruneCache 删除超过指定时间未使用的缓存仓库
+// 有未提交修改或未推送提交的仓库会被保留，除非请求设置了 force
+func (s *Service) handlePruneCache(c *gin.Context) {
+	var req PruneCacheRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid request: %v", err), err))
+		return
+	}
+	age, err := parseAge(req.OlderThan)
+	if err != nil {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, err.Error(), err))
+		return
+	}
+
+	entries, err := s.cacheEntries(true)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	cutoff := time.Now().Add(-age)
+	removed := []CacheEntry{}
+	skipped := []SkippedCacheEntry{}
+	var freed int64
+	for _, e := range entries {
+		if e.LastUsed.After(cutoff) {
+			continue
+		}
+		if !req.Force {
+			reason, err := s.gitClient.LocalChanges(e.Path)
+			if err != nil {
+				reason = fmt.Sprintf("cannot check local changes: %v", err)
+			}
+			if reason != "" {
+				s.log.Info("Keeping cached repository with local changes", "path", e.Path, "reason", reason)
+				skipped = append(skipped, SkippedCacheEntry{Name: e.Name, Path: e.Path, Reason: reason})
+				continue
+			}
+		}
+		if !req.DryRun {
+			s.log.Info("Pruning cached repository", "path", e.Path)
+			if err := os.RemoveAll(e.Path); err != nil {
+				respondError(c, fmt.Errorf("failed to remove %s after removing %d repositories: %w", e.Name, len(removed), err))
+				return
+			}
+		}
+		removed = append(removed, e)
+		freed += e.Size
+	}
+
+	c.JSON(200, PruneCacheResponse{
+		Status:  "ok",
+		DryRun:  req.DryRun,
+		Removed: removed,
+		Skipped: skipped,
+		Freed:   freed,
+	})
+}
+
+// cacheEntries 列出缓存目录中的仓库，withSize 为 true 时计算每个仓库的大小
+func (s *Service) cacheEntries(withSize bool) ([]CacheEntry, error) {
+	dirs, err := os.ReadDir(s.cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []CacheEntry{}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.cacheDir, d.Name())
+		entry := CacheEntry{Name: d.Name(), Path: path, LastUsed: lastUsed(path)}
+		if withSize {
+			entry.Size = dirSize(path)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// lastUsed 返回仓库最近一次 checkout 或 fetch 的时间
+// git 在这些操作时会更新 .git/HEAD、.git/index 或 .git/FETCH_HEAD
+func lastUsed(repoPath string) time.Time {
+	var latest time.Time
+	for _, name := range []string{"", ".git", ".git/HEAD", ".git/index", ".git/FETCH_HEAD"} {
+		if info, err := os.Stat(filepath.Join(repoPath, name)); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// dirSize 计算目录下所有文件的大小，不跟随符号链接
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// parseAge 解析时间长度，在 time.ParseDuration 的基础上支持以天为单位，如 "30d"
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	config := DefaultConfig()
+	service, err := newService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 缓存目录的上一层保存着配置、历史和收藏
+	configFile := filepath.Join(filepath.Dir(config.CacheDir), "config.json")
+	if err := os.WriteFile(configFile, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"acme-web/.git", "acme-api/.git", "not-a-repo"} {
+		if err := os.MkdirAll(filepath.Join(config.CacheDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		repo     string
+		wantCode ErrorCode
+	}{
+		{"parent directory", "..", ErrCodeInvalidRequest},
+		{"cache directory", ".", ErrCodeInvalidRequest},
+		{"backslash", `acme-web\..\..`, ErrCodeInvalidRequest},
+		{"missing", "acme-missing", ErrCodeNotFound},
+		{"not a repository", "not-a-repo", ErrCodeNotFound},
+		{"repository", "acme-web", ""},
+	}
+	router := service.router()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("DELETE", "/cache/"+tt.repo, nil))
+			var resp struct {
+				Code ErrorCode `json:"code"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			if resp.Code != tt.wantCode {
+				t.Errorf("DELETE /cache/%s: HTTP %d, code %q, want %q", tt.repo, rec.Code, resp.Code, tt.wantCode)
+			}
+		})
+	}
+
+	for _, path := range []string{configFile, filepath.Join(config.CacheDir, "acme-api"), filepath.Join(config.CacheDir, "not-a-repo")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(config.CacheDir, "acme-web")); !os.IsNotExist(err) {
+		t.Errorf("acme-web was not removed: %v", err)
+	}
+}
@@ -0,0 +1,819 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/github-browser/service/client"
+)
+
+const cliUsage = `Usage: github-browser-service [--addr URL] [--local] [--json] <command> [args]
+
+Commands:
+  serve                          启动 HTTP 服务（不带命令时的默认行为）
+  open <url> [flags]             克隆/更新仓库并在 IDE 中打开
+  resolve <url> [flags]          预演 open，不克隆也不启动 IDE
+  ides                           列出 IDE 及安装情况
+  cache ls                       列出缓存的仓库
+  cache rm <name>...             删除缓存的仓库
+  cache prune --older-than 30d   删除长时间未使用的仓库（--dry-run 只列出，--force 包括有本地修改的仓库）
+  cache size                     统计缓存占用的空间
+  config get [key]               查看配置，key 为以点分隔的字段路径，如 git.depth
+  config set <key> <value>       修改配置，value 按 JSON 解析，失败时作为字符串
+  config validate [file]         检查配置文件
+  logs [--request ID] [flags]    查看服务日志（--level debug|info|warn|error，-n 条数）
+  history [flags]                查看打开历史（--repo owner/repo，--recent 每个仓库一条，--status，--since 7d，-n 条数）
+  history reopen <id> [--ide N]  按历史记录再次打开
+  history clear [--repo R]       删除打开历史
+  favorites [ls]                 列出收藏的仓库
+  favorites add <url> [flags]    收藏仓库（--name 显示名称，--ide 使用的 IDE）
+  favorites rm <owner/repo>...   取消收藏
+  handle-url <link>              处理 github-browser:// 链接（由桌面环境调用）
+  url-handler install|uninstall  注册 github-browser:// 链接处理程序（Linux，--x-github-client 同时注册 GitHub Desktop 链接）
+  native-host run                作为浏览器扩展的 native messaging host 运行（由浏览器启动）
+  native-host install|uninstall  写入/删除 host manifest（Linux，--chrome ID,... --firefox ID,...）
+  openapi                        输出描述 HTTP API 的 OpenAPI 3 文档，与 GET /openapi.json 相同（服务地址为默认端口）
+
+Global flags:
+  --addr URL   服务地址，http://host:port 或 unix:<socket>，默认先尝试 Unix socket 再尝试 http://localhost:<port>
+  --local      不连接服务，直接在当前进程中执行
+  --json       输出原始 JSON，便于脚本处理
+
+open/resolve flags:
+  --ide NAME  --file PATH  --line N  --column N  --target local|devcontainer|ssh
+`
+
+// 不对应错误码的退出码
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// cliOptions 是所有子命令共用的全局参数
+type cliOptions struct {
+	addr  string
+	local bool
+	json  bool
+}
+
+// runCLI 解析命令行并执行子命令，返回进程退出码
+func runCLI(args []string) int {
+	if isNativeHostInvocation(args) {
+		return runNativeHost(cliOptions{})
+	}
+
+	global := flag.NewFlagSet("github-browser-service", flag.ContinueOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, cliUsage) }
+	var opts cliOptions
+	global.StringVar(&opts.addr, "addr", "", "service address")
+	global.BoolVar(&opts.local, "local", false, "run in-process")
+	global.BoolVar(&opts.json, "json", false, "print JSON")
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	args = global.Args()
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "serve":
+		if err := serve(); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return exitError
+		}
+		return exitOK
+	case "open", "resolve":
+		return cliOpen(opts, command, args)
+	case "ides":
+		return cliIDEs(opts)
+	case "cache":
+		return cliCache(opts, args)
+	case "config":
+		return cliConfig(opts, args)
+	case "logs":
+		return cliLogs(opts, args)
+	case "history":
+		return cliHistory(opts, args)
+	case "favorites":
+		return cliFavorites(opts, args)
+	case "handle-url":
+		return cliHandleURL(opts, args)
+	case "url-handler":
+		return cliURLHandler(args)
+	case "native-host":
+		return cliNativeHost(opts, args)
+	case "openapi":
+		// 不读取配置文件，生成 Go 客户端时不会创建或迁移配置
+		printJSON(openAPIDocument(fmt.Sprintf("http://localhost:%d", DefaultPort)))
+		return exitOK
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", command, cliUsage)
+		return exitUsage
+	}
+}
+
+// apiClient 通过 client 包调用服务的 HTTP API；服务未运行时通过进程内的路由处理请求
+type apiClient struct {
+	api  *client.Client
+	addr string // 服务地址，用于日志：URL、unix:<socket> 或 in-process
+}
+
+// unixSocketPrefix 是 --addr 和 GITHUB_BROWSER_ADDR 中表示 Unix socket 的前缀，如 unix:/run/user/1000/github-browser.sock
+const unixSocketPrefix = "unix:"
+
+// newAPIClient 优先连接正在运行的服务，连接不上时在当前进程中创建服务
+// 未指定地址时先尝试配置的 Unix socket，再尝试 TCP 端口
+func newAPIClient(opts cliOptions) (*apiClient, error) {
+	if !opts.local {
+		addr := opts.addr
+		if addr == "" {
+			addr = os.Getenv("GITHUB_BROWSER_ADDR")
+		}
+		var candidates []string
+		if addr != "" {
+			candidates = []string{strings.TrimSuffix(addr, "/")}
+		} else {
+			config, err := LoadConfig()
+			if err != nil {
+				config = &Config{}
+			}
+			port := config.Port
+			if port == 0 {
+				port = DefaultPort
+			}
+			candidates = []string{
+				unixSocketPrefix + config.Listen.socketPath(),
+				fmt.Sprintf("http://localhost:%d", port),
+			}
+		}
+
+		for _, candidate := range candidates {
+			c := serviceClient(candidate)
+			if c.ping() {
+				return c, nil
+			}
+		}
+		if addr != "" {
+			return nil, fmt.Errorf("service is not reachable at %s", candidates[0])
+		}
+	}
+
+	// 进程内执行时不输出 Gin 的请求日志，服务日志仍写到 stderr
+	gin.DefaultWriter = io.Discard
+	service, err := newService(loadConfigOrDefault())
+	if err != nil {
+		return nil, err
+	}
+	return &apiClient{
+		api:  client.NewWithHTTPClient("http://in-process", &http.Client{Transport: handlerTransport{service.router()}}),
+		addr: "in-process",
+	}, nil
+}
+
+// serviceClient 返回连接 addr 的客户端，addr 为服务 URL 或 unix:<socket>
+func serviceClient(addr string) *apiClient {
+	return &apiClient{api: client.New(addr), addr: addr}
+}
+
+// ping 检查服务是否可以连接
+func (c *apiClient) ping() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	// 服务返回错误（如正在停止）时也说明可以连接
+	_, err := c.api.Health(ctx)
+	var apiErr *client.Error
+	return err == nil || errors.As(err, &apiErr)
+}
+
+// handlerTransport 把请求直接交给 http.Handler 处理，不经过网络
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// call 发送请求，返回 HTTP 状态码和响应体
+func (c *apiClient) call(method, path string, body interface{}) (int, []byte, error) {
+	return c.api.Raw(context.Background(), method, path, body)
+}
+
+// request 调用 API 并输出结果，human 用于非 --json 模式下格式化成功的响应
+func request(opts cliOptions, method, path string, body interface{}, human func(map[string]interface{})) int {
+	client, err := newAPIClient(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	status, data, err := client.call(method, path, body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	return printResponse(opts, status, data, human)
+}
+
+// printResponse 输出响应并根据错误码或 HTTP 状态码返回退出码
+func printResponse(opts cliOptions, status int, data []byte, human func(map[string]interface{})) int {
+	var resp map[string]interface{}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ invalid response (HTTP %d): %s\n", status, data)
+		return exitError
+	}
+
+	failed := status >= 400 || resp["status"] == "error"
+	if opts.json {
+		printJSON(resp)
+	} else if failed {
+		printError(resp)
+	} else {
+		human(resp)
+	}
+
+	if !failed {
+		return exitOK
+	}
+	if code, ok := resp["code"].(string); ok && code != "" {
+		return ErrorCode(code).ExitCode()
+	}
+	if status >= 400 && status < 500 {
+		return exitUsage
+	}
+	return exitError
+}
+
+// printError 输出错误信息和修复建议
+func printError(resp map[string]interface{}) {
+	message, _ := resp["message"].(string)
+	if message == "" {
+		message, _ = resp["error"].(string)
+	}
+	fmt.Fprintf(os.Stderr, "❌ %s\n", message)
+	if hint, _ := resp["hint"].(string); hint != "" {
+		fmt.Fprintf(os.Stderr, "💡 %s\n", hint)
+	}
+	if details, _ := resp["details"].(string); details != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", details)
+	}
+	for _, fe := range asList(resp["errors"]) {
+		fmt.Fprintf(os.Stderr, "   %s: %s\n", fe["field"], fe["message"])
+	}
+	if id, _ := resp["requestId"].(string); id != "" {
+		fmt.Fprintf(os.Stderr, "🔎 Request ID: %s (github-browser-service logs --request %s)\n", id, id)
+	}
+}
+
+func printJSON(v interface{}) {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(data))
+}
+
+// parseInterspersed 解析参数，允许参数和位置参数交替出现，如 open <url> --ide zed
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func cliOpen(opts cliOptions, command string, args []string) int {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	var req OpenRequest
+	fs.StringVar(&req.IDE, "ide", "", "IDE name")
+	fs.StringVar(&req.FilePath, "file", "", "file path relative to the repository")
+	fs.IntVar(&req.Line, "line", 0, "line number")
+	fs.IntVar(&req.Column, "column", 0, "column number")
+	fs.StringVar(&req.Target, "target", "", "local, devcontainer or ssh")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s <url> [--ide NAME] [--file PATH] [--line N]\n", command)
+		return exitUsage
+	}
+	req.URL = positional[0]
+
+	return request(opts, "POST", "/"+command, req, func(resp map[string]interface{}) {
+		if command == "open" {
+			fmt.Printf("✅ Opened %s in %s\n", resp["path"], resp["ide"])
+			if skipped, ok := resp["skipped"].([]interface{}); ok {
+				for _, s := range skipped {
+					fmt.Printf("⚠️  skipped %s\n", s)
+				}
+			}
+			return
+		}
+		fmt.Printf("repo:    %s (exists: %v)\n", resp["repoPath"], resp["exists"])
+		if ref, ok := resp["ref"].(string); ok {
+			fmt.Printf("ref:     %s %s\n", ref, stringOr(resp["sha"], ""))
+		}
+		fmt.Printf("target:  %s\n", resp["targetPath"])
+		fmt.Printf("ide:     %s\n", resp["ide"])
+		if cmd, ok := resp["command"].([]interface{}); ok {
+			parts := make([]string, len(cmd))
+			for i, c := range cmd {
+				parts[i] = fmt.Sprint(c)
+			}
+			fmt.Printf("command: %s\n", shellJoin(parts))
+		}
+	})
+}
+
+func cliIDEs(opts cliOptions) int {
+	return request(opts, "GET", "/ides", nil, func(resp map[string]interface{}) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tAVAILABLE\tPATH\tVERSION")
+		for _, item := range asList(resp["ides"]) {
+			fmt.Fprintf(w, "%s\t%v\t%s\t%s\n", item["name"], item["available"], stringOr(item["path"], "-"), stringOr(item["version"], ""))
+		}
+		w.Flush()
+	})
+}
+
+// cliLogs 打印日志文件中的记录，--request 只显示某个请求的日志
+func cliLogs(opts cliOptions, args []string) int {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	id := fs.String("request", "", "only show logs of this request ID")
+	level := fs.String("level", "", "minimum level: debug, info, warn or error")
+	limit := fs.Int("n", 0, "number of entries to show")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	query := url.Values{}
+	if *id != "" {
+		query.Set("request", *id)
+	}
+	if *level != "" {
+		query.Set("level", *level)
+	}
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+	path := "/logs"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return request(opts, "GET", path, nil, func(resp map[string]interface{}) {
+		for _, entry := range asList(resp["entries"]) {
+			fmt.Println(formatLogEntry(entry))
+		}
+	})
+}
+
+// formatLogEntry 把 JSON 日志记录格式化为一行：时间、级别、消息和其他字段
+func formatLogEntry(entry map[string]interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s %s", stringOr(entry["time"], ""), stringOr(entry["level"], ""), stringOr(entry["msg"], ""))
+	keys := make([]string, 0, len(entry))
+	for key := range entry {
+		switch key {
+		case "time", "level", "msg":
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, entry[key])
+	}
+	return b.String()
+}
+
+func cliHistory(opts cliOptions, args []string) int {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "reopen":
+			fs := flag.NewFlagSet("history reopen", flag.ContinueOnError)
+			var req ReopenRequest
+			fs.StringVar(&req.IDE, "ide", "", "IDE name")
+			fs.StringVar(&req.Target, "target", "", "local, devcontainer or ssh")
+			positional, err := parseInterspersed(fs, args[1:])
+			if err != nil {
+				return exitUsage
+			}
+			if len(positional) != 1 {
+				fmt.Fprintln(os.Stderr, "usage: history reopen <id> [--ide NAME]")
+				return exitUsage
+			}
+			return request(opts, "POST", "/history/"+url.PathEscape(positional[0])+"/reopen", req, func(resp map[string]interface{}) {
+				fmt.Printf("✅ Opened %s in %s\n", resp["path"], resp["ide"])
+			})
+
+		case "clear":
+			fs := flag.NewFlagSet("history clear", flag.ContinueOnError)
+			repo := fs.String("repo", "", "only remove entries of this repository")
+			if err := fs.Parse(args[1:]); err != nil {
+				return exitUsage
+			}
+			path := "/history"
+			if *repo != "" {
+				path += "?" + url.Values{"repo": {*repo}}.Encode()
+			}
+			return request(opts, "DELETE", path, nil, func(resp map[string]interface{}) {
+				fmt.Printf("🗑️  Removed %v entries\n", resp["removed"])
+			})
+
+		default:
+			fmt.Fprintf(os.Stderr, "unknown history command: %s\n", args[0])
+			return exitUsage
+		}
+	}
+
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	repo := fs.String("repo", "", "owner/repo or owner")
+	status := fs.String("status", "", "ok or error")
+	since := fs.String("since", "", "only entries newer than this, e.g. 7d")
+	recent := fs.Bool("recent", false, "only the latest entry of each repository")
+	limit := fs.Int("n", 0, "number of entries to show")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{"repo": *repo, "status": *status, "since": *since} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if *recent {
+		query.Set("distinct", "true")
+	}
+	if *limit > 0 {
+		query.Set("limit", strconv.Itoa(*limit))
+	}
+	path := "/history"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return request(opts, "GET", path, nil, func(resp map[string]interface{}) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tSTATUS\tIDE\tURL")
+		for _, e := range asList(resp["entries"]) {
+			t, _ := time.Parse(time.RFC3339, stringOr(e["time"], ""))
+			status := stringOr(e["status"], "")
+			if code := stringOr(e["code"], ""); code != "" {
+				status = code
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e["id"], t.Local().Format("2006-01-02 15:04"), status, stringOr(e["ide"], "-"), e["url"])
+		}
+		w.Flush()
+	})
+}
+
+func cliFavorites(opts cliOptions, args []string) int {
+	if len(args) == 0 {
+		args = []string{"ls"}
+	}
+
+	switch args[0] {
+	case "ls":
+		return request(opts, "GET", "/favorites", nil, func(resp map[string]interface{}) {
+			for _, f := range asList(resp["favorites"]) {
+				fmt.Printf("%s\t%s\t%s\n", f["repo"], stringOr(f["name"], "-"), f["url"])
+			}
+		})
+
+	case "add":
+		fs := flag.NewFlagSet("favorites add", flag.ContinueOnError)
+		var req FavoriteRequest
+		fs.StringVar(&req.Name, "name", "", "display name")
+		fs.StringVar(&req.IDE, "ide", "", "IDE name")
+		positional, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return exitUsage
+		}
+		if len(positional) != 1 {
+			fmt.Fprintln(os.Stderr, "usage: favorites add <url> [--name NAME] [--ide NAME]")
+			return exitUsage
+		}
+		req.URL = positional[0]
+		return request(opts, "PUT", "/favorites", req, func(resp map[string]interface{}) {
+			if f, ok := resp["favorite"].(map[string]interface{}); ok {
+				fmt.Printf("⭐ Added %s\n", f["repo"])
+			}
+		})
+
+	case "rm":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: favorites rm <owner/repo>...")
+			return exitUsage
+		}
+		for _, repo := range args[1:] {
+			key := "repo"
+			if strings.Contains(repo, "://") {
+				key = "url"
+			}
+			code := request(opts, "DELETE", "/favorites?"+url.Values{key: {repo}}.Encode(), nil, func(resp map[string]interface{}) {
+				fmt.Printf("🗑️  Removed %s\n", resp["removed"])
+			})
+			if code != exitOK {
+				return code
+			}
+		}
+		return exitOK
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown favorites command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+func cliCache(opts cliOptions, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cache ls|rm|prune|size")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "ls":
+		return request(opts, "GET", "/cache", nil, func(resp map[string]interface{}) {
+			for _, item := range asList(resp["repos"]) {
+				fmt.Printf("%s\t%s\n", item["name"], item["path"])
+			}
+		})
+
+	case "rm":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: cache rm <name>...")
+			return exitUsage
+		}
+		for _, name := range args[1:] {
+			code := request(opts, "DELETE", "/cache/"+url.PathEscape(name), nil, func(map[string]interface{}) {
+				fmt.Printf("🗑️  Removed %s\n", name)
+			})
+			if code != exitOK {
+				return code
+			}
+		}
+		return exitOK
+
+	case "prune":
+		fs := flag.NewFlagSet("cache prune", flag.ContinueOnError)
+		var req PruneCacheRequest
+		fs.StringVar(&req.OlderThan, "older-than", "", "remove repositories unused for this long, e.g. 30d")
+		fs.BoolVar(&req.DryRun, "dry-run", false, "only list repositories that would be removed")
+		fs.BoolVar(&req.Force, "force", false, "also remove repositories with uncommitted changes or unpushed commits")
+		if err := fs.Parse(args[1:]); err != nil {
+			return exitUsage
+		}
+		if req.OlderThan == "" {
+			fmt.Fprintln(os.Stderr, "usage: cache prune --older-than 30d [--dry-run] [--force]")
+			return exitUsage
+		}
+		return request(opts, "POST", "/cache/prune", req, func(resp map[string]interface{}) {
+			verb := "Removed"
+			if req.DryRun {
+				verb = "Would remove"
+			}
+			for _, item := range asList(resp["removed"]) {
+				fmt.Printf("%s %s\n", verb, item["name"])
+			}
+			for _, item := range asList(resp["skipped"]) {
+				fmt.Printf("Kept %s: %s (use --force to remove)\n", item["name"], item["reason"])
+			}
+			fmt.Printf("%s %d repositories, %s\n", verb, len(asList(resp["removed"])), formatBytes(resp["freed"]))
+		})
+
+	case "size":
+		return request(opts, "GET", "/cache/size", nil, func(resp map[string]interface{}) {
+			for _, item := range asList(resp["repos"]) {
+				fmt.Printf("%10s  %s\n", formatBytes(item["size"]), item["name"])
+			}
+			fmt.Printf("%10s  total\n", formatBytes(resp["total"]))
+		})
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown cache command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+func cliConfig(opts cliOptions, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: config get|set|validate")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "get":
+		client, err := newAPIClient(opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return exitError
+		}
+		status, data, err := client.call("GET", "/config", nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return exitError
+		}
+		if status >= 400 || len(args) < 2 {
+			return printResponse(opts, status, data, func(resp map[string]interface{}) { printJSON(resp) })
+		}
+
+		var config map[string]interface{}
+		json.Unmarshal(data, &config)
+		value, ok := getConfigKey(config, args[1])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "❌ config key not set: %s\n", args[1])
+			return exitError
+		}
+		if s, isString := value.(string); isString && !opts.json {
+			fmt.Println(s)
+		} else {
+			printJSON(value)
+		}
+		return exitOK
+
+	case "set":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: config set <key> <value>")
+			return exitUsage
+		}
+		client, err := newAPIClient(opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return exitError
+		}
+		status, data, err := client.call("GET", "/config", nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return exitError
+		}
+		if status >= 400 {
+			return printResponse(opts, status, data, nil)
+		}
+
+		var config map[string]interface{}
+		json.Unmarshal(data, &config)
+		var value interface{}
+		if err := json.Unmarshal([]byte(args[2]), &value); err != nil {
+			value = args[2]
+		}
+		if err := setConfigKey(config, args[1], value); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return exitUsage
+		}
+		status, data, err = client.call("PUT", "/config", config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			return exitError
+		}
+		return printResponse(opts, status, data, func(map[string]interface{}) {
+			fmt.Printf("✅ %s = %s\n", args[1], args[2])
+		})
+
+	case "validate":
+		// 不指定文件时检查合并后的系统、团队、用户配置和环境变量
+		path := ConfigPath()
+		validate := validateConfigLayers
+		if len(args) > 1 {
+			path = args[1]
+			validate = func() error { return validateConfigFile(path) }
+		}
+		resp := map[string]interface{}{"status": "ok", "message": "config is valid", "path": path}
+		if err := validate(); err != nil {
+			resp = map[string]interface{}{"status": "error", "message": err.Error(), "code": string(ErrCodeInvalidRequest), "path": path}
+		}
+		data, _ := json.Marshal(resp)
+		return printResponse(opts, 200, data, func(map[string]interface{}) {
+			fmt.Printf("✅ %s is valid\n", path)
+		})
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config command: %s\n", args[0])
+		return exitUsage
+	}
+}
+
+// validateConfigFile 检查配置文件能否解析、是否包含未知字段并通过校验
+func validateConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, _, err = parseConfig(data)
+	return err
+}
+
+// validateConfigLayers 检查所有配置层能否合并并通过校验
+func validateConfigLayers() error {
+	layers, _, err := readConfigLayers()
+	if err == nil {
+		_, err = resolveLayers(layers)
+	}
+	return err
+}
+
+// getConfigKey 按以点分隔的路径读取配置项，数组使用下标，如 pathMappings.0.localPath
+func getConfigKey(config interface{}, key string) (interface{}, bool) {
+	current := config
+	for _, part := range strings.Split(key, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setConfigKey 按以点分隔的路径设置配置项，中间缺少的对象会被创建
+func setConfigKey(config map[string]interface{}, key string, value interface{}) error {
+	parts := strings.Split(key, ".")
+	node := config
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := node[part]
+		if !ok || next == nil {
+			child := map[string]interface{}{}
+			node[part] = child
+			node = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot set %s: %s is not an object", key, part)
+		}
+		node = child
+	}
+	node[parts[len(parts)-1]] = value
+	return nil
+}
+
+// asList 将 JSON 数组转换为对象列表
+func asList(v interface{}) []map[string]interface{} {
+	items, _ := v.([]interface{})
+	list := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+func stringOr(v interface{}, fallback string) string {
+	if s, ok := v.(string); ok && s != "" {
+		return s
+	}
+	return fallback
+}
+
+// formatBytes 将字节数格式化为易读的形式
+func formatBytes(v interface{}) string {
+	n, _ := v.(float64)
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
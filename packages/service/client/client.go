@@ -0,0 +1,335 @@
+// Package client 是 GitHub Browser 服务 HTTP API 的 Go 客户端
+//
+//	c := client.New("")  // 默认 http://localhost:9527，也可以是 unix:/run/user/1000/github-browser.sock
+//	resp, err := c.Open(ctx, &client.OpenRequest{URL: "https://github.com/golang/go"})
+//	var apiErr *client.Error
+//	if errors.As(err, &apiErr) && apiErr.Code == client.ErrCodeIDENotInstalled {
+//		...
+//	}
+//
+// 请求和响应的类型由 go generate 从服务源码生成，见 types.go
+package client
+
+//go:generate go run ./internal/gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultAddr 是服务的默认地址
+const DefaultAddr = "http://localhost:9527"
+
+// unixSocketPrefix 是 Unix socket 地址的前缀，与命令行的 --addr 相同
+const unixSocketPrefix = "unix:"
+
+// Client 调用服务的 HTTP API，可以在多个 goroutine 中同时使用
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// New 返回连接 addr 的客户端
+// addr 为服务 URL（如 http://localhost:9527）或 unix:<socket 路径>，为空时使用 DefaultAddr
+func New(addr string) *Client {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	socket, ok := strings.CutPrefix(addr, unixSocketPrefix)
+	if !ok {
+		return &Client{baseURL: strings.TrimSuffix(addr, "/"), http: &http.Client{}}
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{baseURL: "http://localhost", http: &http.Client{Transport: transport}}
+}
+
+// NewWithHTTPClient 返回使用 httpClient 发送请求的客户端，用于自定义超时、代理或测试
+func NewWithHTTPClient(baseURL string, httpClient *http.Client) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), http: httpClient}
+}
+
+// Error 是服务返回的错误
+type Error struct {
+	StatusCode int          // HTTP 状态码
+	Code       ErrorCode    // 机器可读的错误码，如 ide_not_installed
+	Message    string       // 面向用户的简短描述
+	Hint       string       // 修复建议
+	Details    string       // 脱敏后的原始错误输出
+	RequestID  string       // 可用于 Logs 查询该请求的日志
+	Errors     []FieldError // 配置错误时每个字段的问题
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// errorBody 包含各接口错误响应中的字段
+type errorBody struct {
+	Code      ErrorCode    `json:"code"`
+	Message   string       `json:"message"`
+	Error     string       `json:"error"`
+	Hint      string       `json:"hint"`
+	Details   string       `json:"details"`
+	RequestID string       `json:"requestId"`
+	Errors    []FieldError `json:"errors"`
+}
+
+// Raw 发送请求并返回 HTTP 状态码和原始响应体，body 不为 nil 时作为 JSON 请求体
+// 错误状态码不会转换为 *Error，用于命令行和 native messaging host 转发任意请求
+func (c *Client) Raw(ctx context.Context, method, path string, body interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+// do 发送请求，body 不为 nil 时作为 JSON 请求体，成功的响应解析到 out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	status, data, err := c.Raw(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	if status >= 400 {
+		apiErr := &Error{StatusCode: status, Message: strings.TrimSpace(string(data))}
+		var eb errorBody
+		if json.Unmarshal(data, &eb) == nil {
+			apiErr.Code, apiErr.Hint, apiErr.Details = eb.Code, eb.Hint, eb.Details
+			apiErr.RequestID, apiErr.Errors = eb.RequestID, eb.Errors
+			if eb.Message != "" {
+				apiErr.Message = eb.Message
+			} else if eb.Error != "" {
+				apiErr.Message = eb.Error
+			}
+		}
+		return apiErr
+	}
+	if raw, ok := out.(*json.RawMessage); ok {
+		*raw = data
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// call 发送请求并把成功的响应解析为 T
+func call[T any](ctx context.Context, c *Client, method, path string, query url.Values, body interface{}) (*T, error) {
+	var resp T
+	if err := c.do(ctx, method, path, query, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Health 返回服务状态和版本
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	return call[HealthResponse](ctx, c, "GET", "/health", nil, nil)
+}
+
+// Open 克隆或更新仓库并在 IDE 中打开
+func (c *Client) Open(ctx context.Context, req *OpenRequest) (*OpenResponse, error) {
+	return call[OpenResponse](ctx, c, "POST", "/open", nil, req)
+}
+
+// OpenBatch 在同一个 IDE 窗口中打开多个仓库；部分仓库失败时 Status 为 partial，不返回错误
+func (c *Client) OpenBatch(ctx context.Context, req *BatchOpenRequest) (*BatchOpenResponse, error) {
+	return call[BatchOpenResponse](ctx, c, "POST", "/open/batch", nil, req)
+}
+
+// Resolve 返回 Open 对同一请求会执行的操作，不会克隆、获取或启动 IDE
+func (c *Client) Resolve(ctx context.Context, req *OpenRequest) (*ResolveResponse, error) {
+	return call[ResolveResponse](ctx, c, "POST", "/resolve", nil, req)
+}
+
+// TestMapping 返回 URL 命中的路径映射规则，req.PathMappings 为空时使用当前配置
+func (c *Client) TestMapping(ctx context.Context, req *MappingTestRequest) (*MappingTestResponse, error) {
+	return call[MappingTestResponse](ctx, c, "POST", "/mappings/test", nil, req)
+}
+
+// ListIDEs 返回 IDE 及其安装情况
+func (c *Client) ListIDEs(ctx context.Context) (*IDEListResponse, error) {
+	return call[IDEListResponse](ctx, c, "GET", "/ides", nil, nil)
+}
+
+// ListCache 返回缓存目录中的仓库
+func (c *Client) ListCache(ctx context.Context) (*CacheListResponse, error) {
+	return call[CacheListResponse](ctx, c, "GET", "/cache", nil, nil)
+}
+
+// CacheSize 返回每个缓存仓库占用的空间
+func (c *Client) CacheSize(ctx context.Context) (*CacheSizeResponse, error) {
+	return call[CacheSizeResponse](ctx, c, "GET", "/cache/size", nil, nil)
+}
+
+// PruneCache 删除长时间未使用的缓存仓库
+func (c *Client) PruneCache(ctx context.Context, req *PruneCacheRequest) (*PruneCacheResponse, error) {
+	return call[PruneCacheResponse](ctx, c, "POST", "/cache/prune", nil, req)
+}
+
+// DeleteCache 删除缓存的仓库，name 为 ListCache 返回的名称
+func (c *Client) DeleteCache(ctx context.Context, name string) (*StatusResponse, error) {
+	return call[StatusResponse](ctx, c, "DELETE", "/cache/"+url.PathEscape(name), nil, nil)
+}
+
+// Config 返回当前生效的配置
+func (c *Client) Config(ctx context.Context) (*Config, error) {
+	return call[Config](ctx, c, "GET", "/config", nil, nil)
+}
+
+// ExplainConfig 返回当前配置、各配置层和每个值的来源
+func (c *Client) ExplainConfig(ctx context.Context) (*ConfigExplainResponse, error) {
+	return call[ConfigExplainResponse](ctx, c, "GET", "/config", url.Values{"explain": {"1"}}, nil)
+}
+
+// ConfigSchema 返回配置文件的 JSON Schema
+func (c *Client) ConfigSchema(ctx context.Context) (json.RawMessage, error) {
+	var resp json.RawMessage
+	if err := c.do(ctx, "GET", "/config/schema", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// UpdateConfig 用 config 替换当前配置，githubToken 按 config 中的值保存
+// 只修改部分字段时使用 PatchConfig
+func (c *Client) UpdateConfig(ctx context.Context, config *Config) (*ConfigUpdateResponse, error) {
+	return call[ConfigUpdateResponse](ctx, c, "PUT", "/config", nil, config)
+}
+
+// PatchConfig 按 JSON Merge Patch（RFC 7386）修改用户配置，值为 nil 的字段恢复为下层配置或默认值
+func (c *Client) PatchConfig(ctx context.Context, patch map[string]interface{}) (*ConfigUpdateResponse, error) {
+	return call[ConfigUpdateResponse](ctx, c, "PATCH", "/config", nil, patch)
+}
+
+// LogsQuery 是 Logs 的查询条件，零值表示不限制
+type LogsQuery struct {
+	RequestID string // 只返回该请求的日志
+	Level     string // 最低级别：debug、info、warn 或 error
+	Limit     int    // 返回最后多少条，默认 200
+}
+
+// Logs 返回日志文件中的记录
+func (c *Client) Logs(ctx context.Context, q LogsQuery) (*LogsResponse, error) {
+	query := url.Values{}
+	setQuery(query, "request", q.RequestID)
+	setQuery(query, "level", q.Level)
+	setQueryInt(query, "limit", q.Limit)
+	return call[LogsResponse](ctx, c, "GET", "/logs", query, nil)
+}
+
+// HistoryQuery 是 History 的查询条件，零值表示不限制
+type HistoryQuery struct {
+	Repo     string // owner/repo 或 owner
+	Query    string // 在 URL、仓库和文件路径中查找
+	Status   string // ok 或 error
+	IDE      string
+	Since    string // RFC 3339 时间，或 7d、24h 这样的相对时间
+	Distinct bool   // 每个仓库只返回最近的一条
+	Limit    int    // 默认 50，最大 500
+	Offset   int
+}
+
+// History 返回打开历史，按时间从新到旧排列
+func (c *Client) History(ctx context.Context, q HistoryQuery) (*HistoryResponse, error) {
+	query := url.Values{}
+	setQuery(query, "repo", q.Repo)
+	setQuery(query, "q", q.Query)
+	setQuery(query, "status", q.Status)
+	setQuery(query, "ide", q.IDE)
+	setQuery(query, "since", q.Since)
+	if q.Distinct {
+		query.Set("distinct", "true")
+	}
+	setQueryInt(query, "limit", q.Limit)
+	setQueryInt(query, "offset", q.Offset)
+	return call[HistoryResponse](ctx, c, "GET", "/history", query, nil)
+}
+
+// ClearHistory 删除打开历史，repo 不为空时只删除该仓库的记录
+func (c *Client) ClearHistory(ctx context.Context, repo string) (*ClearHistoryResponse, error) {
+	query := url.Values{}
+	setQuery(query, "repo", repo)
+	return call[ClearHistoryResponse](ctx, c, "DELETE", "/history", query, nil)
+}
+
+// Reopen 按历史记录再次打开，override 中不为空的字段覆盖记录中的值，可以为 nil
+func (c *Client) Reopen(ctx context.Context, id string, override *ReopenRequest) (*OpenResponse, error) {
+	var body interface{}
+	if override != nil {
+		body = override
+	}
+	return call[OpenResponse](ctx, c, "POST", "/history/"+url.PathEscape(id)+"/reopen", nil, body)
+}
+
+// Favorites 返回收藏的仓库
+func (c *Client) Favorites(ctx context.Context) (*FavoritesResponse, error) {
+	return call[FavoritesResponse](ctx, c, "GET", "/favorites", nil, nil)
+}
+
+// PutFavorite 收藏仓库，已收藏时更新名称和 IDE
+func (c *Client) PutFavorite(ctx context.Context, req *FavoriteRequest) (*FavoriteResponse, error) {
+	return call[FavoriteResponse](ctx, c, "PUT", "/favorites", nil, req)
+}
+
+// DeleteFavorite 取消收藏，repo 为 owner/repo
+func (c *Client) DeleteFavorite(ctx context.Context, repo string) (*DeleteFavoriteResponse, error) {
+	return call[DeleteFavoriteResponse](ctx, c, "DELETE", "/favorites", url.Values{"repo": {repo}}, nil)
+}
+
+// OpenAPI 返回服务的 OpenAPI 3 文档
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var resp json.RawMessage
+	if err := c.do(ctx, "GET", "/openapi.json", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func setQuery(query url.Values, name, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
+
+func setQueryInt(query url.Values, name string, value int) {
+	if value != 0 {
+		query.Set(name, strconv.Itoa(value))
+	}
+}
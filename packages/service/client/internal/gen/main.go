@@ -0,0 +1,294 @@
+// gen 生成 client/types.go：从服务的 OpenAPI 文档中取出所有 schema 的名称，
+// 再从服务源码中复制同名类型的定义（连同注释），保证客户端与服务使用相同的结构
+//
+// 在 client 目录中通过 go generate 运行
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	serviceDir := flag.String("service", "..", "directory of the service package")
+	output := flag.String("o", "types.go", "output file")
+	flag.Parse()
+
+	if err := generate(*serviceDir, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// typeDef 是服务源码中的一个类型定义
+type typeDef struct {
+	file *ast.File
+	decl *ast.GenDecl
+	spec *ast.TypeSpec
+}
+
+func generate(serviceDir, output string) error {
+	names, err := schemaNames(serviceDir)
+	if err != nil {
+		return err
+	}
+
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob(filepath.Join(serviceDir, "*.go"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	var files []*ast.File
+	defs := map[string]*typeDef{}
+	var order []string
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				defs[ts.Name.Name] = &typeDef{file: file, decl: gd, spec: ts}
+				order = append(order, ts.Name.Name)
+			}
+		}
+	}
+
+	// 未导出的类型（如 configLayer）在客户端中使用 schema 的名称
+	rename := map[string]string{}
+	selected := map[string]bool{}
+	for _, name := range names {
+		goName := name
+		if defs[goName] == nil {
+			goName = strings.ToLower(name[:1]) + name[1:]
+		}
+		if defs[goName] == nil {
+			return fmt.Errorf("type %s not found in %s", name, serviceDir)
+		}
+		selected[goName] = true
+		if goName != name {
+			rename[goName] = name
+		}
+	}
+
+	var body bytes.Buffer
+	for _, name := range order {
+		if !selected[name] {
+			continue
+		}
+		if err := writeType(&body, fset, defs[name], rename); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		if err := writeConsts(&body, fset, file, selected); err != nil {
+			return err
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by go run ./internal/gen; DO NOT EDIT.\n\npackage client\n\n")
+	var imports []string
+	if bytes.Contains(body.Bytes(), []byte("json.")) {
+		imports = append(imports, `"encoding/json"`)
+	}
+	if bytes.Contains(body.Bytes(), []byte("time.")) {
+		imports = append(imports, `"time"`)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&out, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	}
+	out.Write(body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return fmt.Errorf("format: %w", err)
+	}
+	return os.WriteFile(output, src, 0644)
+}
+
+// schemaNames 运行服务的 openapi 命令，返回文档中所有 schema 的名称
+func schemaNames(serviceDir string) ([]string, error) {
+	cmd := exec.Command("go", "run", ".", "openapi")
+	cmd.Dir = serviceDir
+	cmd.Stderr = os.Stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go run . openapi: %w", err)
+	}
+	var doc struct {
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// writeType 输出类型定义，去掉未导出和不序列化的字段，标签只保留 json
+func writeType(w *bytes.Buffer, fset *token.FileSet, def *typeDef, rename map[string]string) error {
+	spec := *def.spec
+	comments := []*ast.CommentGroup{}
+	doc := def.spec.Doc
+	if doc == nil && len(def.decl.Specs) == 1 {
+		doc = def.decl.Doc
+	}
+	if doc != nil {
+		comments = append(comments, doc)
+	}
+	if spec.Comment != nil {
+		comments = append(comments, spec.Comment)
+	}
+
+	if st, ok := spec.Type.(*ast.StructType); ok {
+		fields := &ast.FieldList{Opening: st.Fields.Opening, Closing: st.Fields.Closing}
+		for _, field := range st.Fields.List {
+			if !exported(field) {
+				continue
+			}
+			field.Tag = jsonTag(field.Tag)
+			renameTypes(field.Type, rename)
+			fields.List = append(fields.List, field)
+			if field.Doc != nil {
+				comments = append(comments, field.Doc)
+			}
+			if field.Comment != nil {
+				comments = append(comments, field.Comment)
+			}
+		}
+		if n := len(fields.List); n > 0 && n < len(st.Fields.List) {
+			// 去掉末尾的字段后，右括号紧跟最后一个保留的字段
+			file := fset.File(st.Pos())
+			fields.Closing = file.LineStart(file.Line(fields.List[n-1].End()) + 1)
+		}
+		spec.Type = &ast.StructType{Struct: st.Struct, Fields: fields}
+	} else {
+		renameTypes(spec.Type, rename)
+	}
+	if name, ok := rename[spec.Name.Name]; ok {
+		spec.Name = ast.NewIdent(name)
+		spec.Name.NamePos = def.spec.Name.NamePos
+		if doc != nil && strings.HasPrefix(doc.List[0].Text, "// "+def.spec.Name.Name+" ") {
+			first := *doc.List[0]
+			first.Text = "// " + name + strings.TrimPrefix(first.Text, "// "+def.spec.Name.Name)
+			doc = &ast.CommentGroup{List: append([]*ast.Comment{&first}, doc.List[1:]...)}
+			comments[0] = doc
+		}
+	}
+
+	decl := &ast.GenDecl{Doc: doc, TokPos: def.spec.Pos(), Tok: token.TYPE, Specs: []ast.Spec{&spec}}
+	if err := printer.Fprint(w, fset, &printer.CommentedNode{Node: decl, Comments: comments}); err != nil {
+		return err
+	}
+	w.WriteString("\n\n")
+	return nil
+}
+
+// writeConsts 输出 file 中类型为已选类型的常量，如 ErrorCode 的各个取值
+func writeConsts(w *bytes.Buffer, fset *token.FileSet, file *ast.File, selected map[string]bool) error {
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.CONST {
+			continue
+		}
+		var specs []ast.Spec
+		var comments []*ast.CommentGroup
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			typ, ok := vs.Type.(*ast.Ident)
+			if !ok || !selected[typ.Name] || !ast.IsExported(typ.Name) {
+				continue
+			}
+			specs = append(specs, vs)
+			for _, c := range []*ast.CommentGroup{vs.Doc, vs.Comment} {
+				if c != nil {
+					comments = append(comments, c)
+				}
+			}
+		}
+		if len(specs) == 0 {
+			continue
+		}
+		out := &ast.GenDecl{TokPos: gd.TokPos, Tok: token.CONST, Lparen: gd.Lparen, Specs: specs, Rparen: gd.Rparen}
+		if err := printer.Fprint(w, fset, &printer.CommentedNode{Node: out, Comments: comments}); err != nil {
+			return err
+		}
+		w.WriteString("\n\n")
+	}
+	return nil
+}
+
+// exported 判断字段是否会被 encoding/json 序列化
+func exported(field *ast.Field) bool {
+	if len(field.Names) == 0 || !field.Names[0].IsExported() {
+		return false
+	}
+	if field.Tag == nil {
+		return true
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	return err != nil || reflect.StructTag(tag).Get("json") != "-"
+}
+
+// jsonTag 去掉 binding 等服务端使用的标签
+func jsonTag(tag *ast.BasicLit) *ast.BasicLit {
+	if tag == nil {
+		return nil
+	}
+	value, err := strconv.Unquote(tag.Value)
+	if err != nil {
+		return tag
+	}
+	name, ok := reflect.StructTag(value).Lookup("json")
+	if !ok {
+		return nil
+	}
+	return &ast.BasicLit{ValuePos: tag.ValuePos, Kind: token.STRING, Value: "`json:" + strconv.Quote(name) + "`"}
+}
+
+// renameTypes 把类型表达式中引用的未导出类型改为导出的名称
+func renameTypes(expr ast.Expr, rename map[string]string) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// time.Time 等其他包中的类型
+			return false
+		case *ast.Ident:
+			if name, ok := rename[n.Name]; ok {
+				n.Name = name
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,545 @@
+// Code generated by go run ./internal/gen; DO NOT EDIT.
+
+package client
+
+import (
+	"time"
+)
+
+// WorkspaceSet 是在配置中保存的一组仓库，可通过名称一次打开
+type WorkspaceSet struct {
+	URLs []string `json:"urls"`
+	IDE  string   `json:"ide,omitempty"` // 为空时使用 defaultIDE
+}
+
+type BatchOpenRequest struct {
+	URLs      []string `json:"urls"`
+	Workspace string   `json:"workspace"` // 配置中 workspaces 的名称，与 urls 二选一
+	IDE       string   `json:"ide"`
+}
+
+// BatchRepoResult 是批量打开中单个仓库的准备结果
+type BatchRepoResult struct {
+	URL     string           `json:"url"`
+	Status  string           `json:"status"`
+	Message string           `json:"message,omitempty"`
+	Code    ErrorCode        `json:"code,omitempty"`
+	Path    string           `json:"path,omitempty"`
+	Skipped []string         `json:"skipped,omitempty"`
+	Hooks   []HookResult     `json:"hooks,omitempty"`
+	Timings map[string]int64 `json:"timings,omitempty"` // 准备该仓库各阶段的耗时（毫秒）
+}
+
+type BatchOpenResponse struct {
+	Status        string            `json:"status"` // ok、partial（部分仓库失败）或 error
+	Message       string            `json:"message"`
+	Code          ErrorCode         `json:"code,omitempty"`
+	Hint          string            `json:"hint,omitempty"`
+	Repos         []BatchRepoResult `json:"repos"`
+	IDE           string            `json:"ide,omitempty"`
+	FallbackFrom  string            `json:"fallbackFrom,omitempty"`
+	WorkspaceFile string            `json:"workspaceFile,omitempty"` // 生成的 .code-workspace 文件
+	Launches      []*LaunchResult   `json:"launches,omitempty"`
+	RequestID     string            `json:"requestId,omitempty"`
+}
+
+// CacheEntry 描述缓存目录中的一个仓库
+type CacheEntry struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	LastUsed time.Time `json:"lastUsed"`       // 最近一次 checkout 或 fetch 的时间
+	Size     int64     `json:"size,omitempty"` // 占用的字节数
+}
+
+type PruneCacheRequest struct {
+	OlderThan string `json:"olderThan"` // 如 "720h" 或 "30d"
+	DryRun    bool   `json:"dryRun"`
+	Force     bool   `json:"force"` // 同时删除有未提交修改或未推送提交的仓库
+}
+
+// SkippedCacheEntry 是因有本地修改而没有删除的缓存仓库
+type SkippedCacheEntry struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"` // 如 "uncommitted changes"、"3 unpushed commits"
+}
+
+// CacheSizeResponse 是 GET /cache/size 的响应，仓库按大小从大到小排列
+type CacheSizeResponse struct {
+	Repos []CacheEntry `json:"repos"`
+	Count int          `json:"count"`
+	Total int64        `json:"total"` // 所有仓库占用的字节数
+}
+
+// PruneCacheResponse 是 POST /cache/prune 的响应
+type PruneCacheResponse struct {
+	Status  string              `json:"status"`
+	DryRun  bool                `json:"dryRun"`
+	Removed []CacheEntry        `json:"removed"` // 删除的仓库，dryRun 时为将要删除的仓库
+	Skipped []SkippedCacheEntry `json:"skipped"` // 有本地修改而保留的仓库，force 时为空
+	Freed   int64               `json:"freed"`   // 释放的字节数
+}
+
+// PathMapping 定义 GitHub 路径到本地目录的映射
+// Pattern 支持：
+//   - "owner" - 匹配特定用户/组织的所有仓库
+//   - "owner/repo" - 匹配特定仓库
+//   - "myorg/svc-*"、"*/infra-*" - 通配符，语法同 path.Match
+//   - "*" - 默认匹配所有
+//
+// 也可以用 Regex 代替 Pattern；匹配顺序见 pathResolver
+type PathMapping struct {
+	Pattern   string       `json:"pattern,omitempty"`  // owner/repo 模式，支持通配符，如 "microsoft"、"microsoft/vscode"、"myorg/svc-*"、"*/infra-*"
+	Regex     string       `json:"regex,omitempty"`    // 匹配 owner/repo 的正则表达式，与 pattern 二选一，命名分组可以在 localPath 中引用
+	Host      string       `json:"host,omitempty"`     // 只匹配该主机上的仓库，支持通配符，为空匹配所有主机
+	Priority  int          `json:"priority,omitempty"` // 数值大的先匹配
+	LocalPath string       `json:"localPath"`          // 本地目录路径，支持 {host}、{owner}、{repo}、{ref} 占位符，相对路径相对于 cacheDir
+	Git       *GitStrategy `json:"git,omitempty"`      // 覆盖全局的克隆/拉取策略
+}
+
+// GitStrategy 定义克隆和拉取仓库的策略
+// 未设置的字段沿用上一层（全局配置或默认值）
+type GitStrategy struct {
+	Filter       string   `json:"filter,omitempty"`       // partial clone 过滤器，如 "blob:none"、"tree:0"；"none" 表示完整克隆
+	Depth        int      `json:"depth,omitempty"`        // 浅克隆深度，0 沿用上一层，DepthFull（-1）表示完整历史
+	SingleBranch *bool    `json:"singleBranch,omitempty"` // 只克隆默认分支
+	Tags         *bool    `json:"tags,omitempty"`         // 是否获取 tag
+	Refspecs     []string `json:"refspecs,omitempty"`     // fetch 时使用的 refspec，为空则获取所有远程
+	Submodules   string   `json:"submodules,omitempty"`   // 子模块策略："recursive" 或 "off"
+	LFS          string   `json:"lfs,omitempty"`          // LFS 策略："path" 仅拉取打开的路径、"all" 或 "off"
+}
+
+// CustomIDE 定义用户自定义的 IDE 启动方式，与内置 IDE 同名时覆盖内置配置
+// 参数、环境变量和工作目录支持占位符：$PATH、$LINE、$COLUMN、$REPO、$FILE
+type CustomIDE struct {
+	Command  string            `json:"command"`            // 可执行文件或脚本
+	Args     []string          `json:"args"`               // 参数模板
+	LineArgs []string          `json:"lineArgs,omitempty"` // 有行号时替代 args 使用的参数模板
+	Env      map[string]string `json:"env,omitempty"`      // 额外的环境变量
+	Dir      string            `json:"dir,omitempty"`      // 工作目录，如 "$REPO"
+	Terminal bool              `json:"terminal,omitempty"` // 是否为需要在终端中运行的编辑器
+}
+
+type Config struct {
+	Schema          string                  `json:"$schema,omitempty"` // 编辑器补全使用的 JSON Schema，见 GET /config/schema
+	Version         int                     `json:"version"`           // 配置文件格式版本，旧版本在加载时自动迁移
+	Include         []string                `json:"include,omitempty"` // 引用的团队配置文件，只在配置文件中使用
+	Port            int                     `json:"port"`
+	DefaultIDE      string                  `json:"defaultIDE"`
+	GitHubToken     string                  `json:"githubToken"`
+	CacheDir        string                  `json:"cacheDir"`
+	GitBackend      string                  `json:"gitBackend,omitempty"`      // git 实现："exec"（默认）或 "go-git"
+	Git             *GitStrategy            `json:"git,omitempty"`             // 全局克隆/拉取策略
+	PathMappings    []PathMapping           `json:"pathMappings,omitempty"`    // 路径映射规则
+	CustomIDEs      map[string]CustomIDE    `json:"customIDEs,omitempty"`      // 自定义 IDE
+	IDERules        []IDERule               `json:"ideRules,omitempty"`        // 请求未指定 IDE 时的选择规则
+	IDEPreference   []string                `json:"idePreference,omitempty"`   // 请求的 IDE 未安装时按顺序尝试的 IDE
+	Terminal        *TerminalConfig         `json:"terminal,omitempty"`        // 终端编辑器使用的终端
+	Reuse           *ReuseConfig            `json:"reuse,omitempty"`           // 复用已运行的编辑器实例
+	Workspaces      map[string]WorkspaceSet `json:"workspaces,omitempty"`      // 可通过名称批量打开的仓库集合
+	Hooks           []Hook                  `json:"hooks,omitempty"`           // clone/checkout 之后、启动 IDE 之前执行的初始化命令
+	DevContainer    bool                    `json:"devContainer,omitempty"`    // 自动在 dev container 中打开含 devcontainer.json 的仓库（仅 VS Code 系列）
+	Remote          *RemoteConfig           `json:"remote,omitempty"`          // 远程开发主机
+	URLHandler      *URLHandlerConfig       `json:"urlHandler,omitempty"`      // github-browser:// 链接允许打开的主机
+	Log             *LogConfig              `json:"log,omitempty"`             // 日志级别、格式和日志文件
+	History         *HistoryConfig          `json:"history,omitempty"`         // 打开历史的保留策略
+	ShutdownTimeout string                  `json:"shutdownTimeout,omitempty"` // 停止服务时等待进行中的请求的时间，如 "1m"，默认 30 秒
+	Listen          *ListenConfig           `json:"listen,omitempty"`          // 监听 TCP 端口和/或 Unix socket
+	AllowedOrigins  []string                `json:"allowedOrigins,omitempty"`  // 接受跨域请求的来源，如 "chrome-extension://<扩展 ID>"，为空时接受所有浏览器扩展
+}
+
+// FieldError 描述一个配置字段的错误
+type FieldError struct {
+	Field   string `json:"field"` // 字段路径，如 pathMappings[0].localPath
+	Message string `json:"message"`
+	Source  string `json:"source,omitempty"` // 提供该值的配置层，如 user:/home/me/.github-browser/config.json
+}
+
+// RemoteConfig 定义远程开发主机，仓库会通过 ssh 在该主机上克隆和更新
+type RemoteConfig struct {
+	Host     string   `json:"host"`               // ssh 主机，可以是 ~/.ssh/config 中的别名或 user@host
+	Port     int      `json:"port,omitempty"`     // JetBrains Gateway 连接使用的端口，默认 22
+	CacheDir string   `json:"cacheDir,omitempty"` // 远程主机上的缓存目录，默认 ~/.github-browser/repos
+	Repos    []string `json:"repos,omitempty"`    // 未指定 target 时默认在远程主机上打开的仓库，owner/repo 模式
+}
+
+// ErrorCode 是返回给客户端的机器可读错误码
+type ErrorCode string
+
+type URLType string
+
+type GitHubURLInfo struct {
+	Owner    string  `json:"owner"`
+	Repo     string  `json:"repo"`
+	Type     URLType `json:"type"`
+	Branch   string  `json:"branch,omitempty"`
+	FilePath string  `json:"filePath,omitempty"`
+	Line     int     `json:"line,omitempty"`
+	PRNumber int     `json:"prNumber,omitempty"`
+}
+
+// HistoryConfig 配置打开历史的保留策略
+type HistoryConfig struct {
+	Disabled   bool   `json:"disabled,omitempty"`   // 不记录打开历史
+	MaxEntries int    `json:"maxEntries,omitempty"` // 最多保留的记录数，默认 1000
+	MaxAge     string `json:"maxAge,omitempty"`     // 记录的保留时间，如 "30d"、"720h"，默认 90 天
+}
+
+// HistoryEntry 是一次打开的记录
+type HistoryEntry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	URL        string    `json:"url"`
+	Repo       string    `json:"repo,omitempty"` // owner/repo，URL 无法解析时为空
+	Type       URLType   `json:"type,omitempty"`
+	Ref        string    `json:"ref,omitempty"`      // 分支、tag 或 commit
+	PRNumber   int       `json:"prNumber,omitempty"` // PR 编号
+	FilePath   string    `json:"filePath,omitempty"`
+	Line       int       `json:"line,omitempty"`
+	Column     int       `json:"column,omitempty"`
+	IDE        string    `json:"ide,omitempty"`    // 实际使用的 IDE
+	Target     string    `json:"target,omitempty"` // local、devcontainer 或 ssh
+	Path       string    `json:"path,omitempty"`   // 本地仓库路径
+	Status     string    `json:"status"`           // ok 或 error
+	Code       ErrorCode `json:"code,omitempty"`   // 失败时的错误码
+	Message    string    `json:"message,omitempty"`
+	Batch      bool      `json:"batch,omitempty"` // 通过 /open/batch 打开
+	DurationMs int64     `json:"durationMs"`
+	RequestID  string    `json:"requestId,omitempty"`
+}
+
+// Favorite 是收藏的仓库
+type Favorite struct {
+	Repo    string    `json:"repo"`           // owner/repo
+	URL     string    `json:"url"`            // 打开收藏时使用的 URL
+	Name    string    `json:"name,omitempty"` // 显示名称
+	IDE     string    `json:"ide,omitempty"`  // 打开时使用的 IDE，为空时按配置选择
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// HistoryResponse 是 GET /history 的响应
+type HistoryResponse struct {
+	Status  string          `json:"status"`
+	Entries []*HistoryEntry `json:"entries"`
+	Total   int             `json:"total"` // 满足条件的记录总数
+	Offset  int             `json:"offset"`
+	Limit   int             `json:"limit"`
+}
+
+// ClearHistoryResponse 是 DELETE /history 的响应
+type ClearHistoryResponse struct {
+	Status  string `json:"status"`
+	Removed int    `json:"removed"` // 删除的记录数
+}
+
+// ReopenRequest 是 POST /history/:id/reopen 的请求，字段覆盖记录中的值
+type ReopenRequest struct {
+	IDE    string `json:"ide"`
+	Target string `json:"target"`
+}
+
+// FavoriteRequest 是 PUT /favorites 的请求
+type FavoriteRequest struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
+	IDE  string `json:"ide"`
+}
+
+// FavoritesResponse 是 GET /favorites 的响应
+type FavoritesResponse struct {
+	Status    string      `json:"status"`
+	Favorites []*Favorite `json:"favorites"`
+	Count     int         `json:"count"`
+}
+
+// FavoriteResponse 是 PUT /favorites 的响应
+type FavoriteResponse struct {
+	Status   string    `json:"status"`
+	Favorite *Favorite `json:"favorite"`
+}
+
+// DeleteFavoriteResponse 是 DELETE /favorites 的响应
+type DeleteFavoriteResponse struct {
+	Status  string `json:"status"`
+	Removed string `json:"removed"` // 取消收藏的 owner/repo
+}
+
+// Hook 是仓库准备好之后执行的初始化命令，如 `go mod download`、`pnpm install`
+type Hook struct {
+	Name    string            `json:"name,omitempty"`
+	Stage   string            `json:"stage"`             // after-clone、after-checkout 或 before-launch
+	Repo    string            `json:"repo,omitempty"`    // owner/repo 模式，与 ideRules 相同；为空匹配所有仓库
+	Markers []string          `json:"markers,omitempty"` // 仓库根目录下存在其中任一文件时才执行，如 "go.mod"
+	Command []string          `json:"command,omitempty"` // 直接执行的命令及参数
+	Shell   string            `json:"shell,omitempty"`   // 通过 sh -c 执行的命令，与 command 二选一
+	Env     map[string]string `json:"env,omitempty"`     // 额外的环境变量
+	Dir     string            `json:"dir,omitempty"`     // 相对仓库根目录的工作目录，不能指向仓库之外
+	Timeout string            `json:"timeout,omitempty"` // 超时时间，如 "2m"，默认 5 分钟
+	// Background 为 true 时不等待命令结束，IDE 会立即打开
+	Background bool `json:"background,omitempty"`
+}
+
+// HookResult 是一次 hook 执行的结果
+type HookResult struct {
+	Name       string `json:"name"`
+	Stage      string `json:"stage"`
+	Status     string `json:"status"` // ok、failed、timeout 或 background
+	ExitCode   int    `json:"exitCode,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+	Output     string `json:"output,omitempty"` // 输出的最后一部分，已脱敏
+	Background bool   `json:"background,omitempty"`
+}
+
+// IDEInfo 描述一个 IDE 在本机的安装情况
+type IDEInfo struct {
+	Name      string `json:"name"`
+	Command   string `json:"command"`
+	Custom    bool   `json:"custom"`
+	Available bool   `json:"available"`
+	Path      string `json:"path,omitempty"`    // 实际使用的可执行文件
+	Source    string `json:"source,omitempty"`  // 找到的位置：path、jetbrains-toolbox、flatpak、snap
+	Version   string `json:"version,omitempty"` // 仅对支持 --version 且不会启动界面的 IDE 探测
+}
+
+// ConfigLayer 是参与合并的一个配置来源
+type ConfigLayer struct {
+	Name   string `json:"name"`             // default、system、team、user 或 env
+	Source string `json:"source,omitempty"` // 文件路径或环境变量名
+}
+
+// ListenConfig 配置服务监听 TCP 端口、Unix socket 或两者
+type ListenConfig struct {
+	TCP    *bool  `json:"tcp,omitempty"`    // 监听 TCP 端口 port，默认 true；浏览器扩展不通过 native messaging 访问时需要
+	Unix   bool   `json:"unix,omitempty"`   // 监听 Unix socket，只有当前用户可以连接
+	Socket string `json:"socket,omitempty"` // Unix socket 路径，默认 $XDG_RUNTIME_DIR/github-browser.sock
+}
+
+// LogConfig 定义服务日志的级别、格式和日志文件
+type LogConfig struct {
+	Level     string `json:"level,omitempty"`     // debug、info、warn 或 error，默认 info；debug 时记录每条 git 命令及耗时
+	Format    string `json:"format,omitempty"`    // 控制台日志格式：text 或 json，默认 text；日志文件总是 JSON
+	File      string `json:"file,omitempty"`      // 日志文件，默认 ~/.github-browser/logs/service.log，"off" 表示不写文件
+	MaxSizeMB int    `json:"maxSizeMB,omitempty"` // 日志文件超过该大小（MB）时轮转，默认 10
+	MaxFiles  int    `json:"maxFiles,omitempty"`  // 保留的旧日志文件数，默认 5
+}
+
+// LogEntry 是日志文件中的一条记录
+type LogEntry map[string]interface{}
+
+// LogsResponse 是 GET /logs 的响应
+type LogsResponse struct {
+	Status  string     `json:"status"`
+	Request string     `json:"request"` // 查询的请求 ID，为空表示所有请求
+	File    string     `json:"file"`    // 日志文件路径
+	Entries []LogEntry `json:"entries"`
+	Count   int        `json:"count"`
+}
+
+type OpenRequest struct {
+	URL      string `json:"url"`
+	IDE      string `json:"ide"`
+	FilePath string `json:"filePath"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Target   string `json:"target"` // local、devcontainer 或 ssh，为空时按配置自动选择
+}
+
+type OpenResponse struct {
+	Status       string           `json:"status"`
+	Message      string           `json:"message"`
+	Code         ErrorCode        `json:"code,omitempty"`    // 错误码，仅在 status 为 error 时返回
+	Hint         string           `json:"hint,omitempty"`    // 修复建议
+	Details      string           `json:"details,omitempty"` // 脱敏后的原始错误输出
+	Path         string           `json:"path,omitempty"`
+	Skipped      []string         `json:"skipped,omitempty"`      // 被跳过的准备步骤及原因
+	IDE          string           `json:"ide,omitempty"`          // 实际使用的 IDE
+	FallbackFrom string           `json:"fallbackFrom,omitempty"` // 未安装而被替换的 IDE
+	Launch       *LaunchResult    `json:"launch,omitempty"`       // 复用了已运行的实例还是启动了新进程
+	Hooks        []HookResult     `json:"hooks,omitempty"`        // 执行的初始化 hook
+	Target       string           `json:"target,omitempty"`       // 实际使用的目标模式：local、devcontainer 或 ssh
+	Timings      map[string]int64 `json:"timings,omitempty"`      // 各阶段耗时（毫秒），如 clone、fetch、checkout、launch
+	RequestID    string           `json:"requestId,omitempty"`    // 请求 ID，可用于 GET /logs?request=<id> 查询该请求的日志
+}
+
+// IDEListResponse 是 GET /ides 的响应
+type IDEListResponse struct {
+	IDEs       []IDEInfo `json:"ides"`
+	DefaultIDE string    `json:"defaultIDE"`
+	Preference []string  `json:"preference"` // 配置中的 idePreference
+}
+
+// CachedRepo 是 GET /cache 列出的一个仓库目录
+type CachedRepo struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Modified time.Time `json:"modified"` // 目录的修改时间
+}
+
+// CacheListResponse 是 GET /cache 的响应
+type CacheListResponse struct {
+	Repos []CachedRepo `json:"repos"`
+	Count int          `json:"count"`
+}
+
+// StatusResponse 是只返回结果说明的响应
+type StatusResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// BuildInfo 描述正在运行的服务的版本
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`    // 构建时的 git commit
+	BuildTime string `json:"buildTime,omitempty"` // commit 的时间
+	Modified  bool   `json:"modified,omitempty"`  // 构建时工作区有未提交的修改
+	GoVersion string `json:"goVersion"`
+}
+
+// HealthResponse 是 GET /health 的响应
+type HealthResponse struct {
+	Status        string    `json:"status"`
+	Version       string    `json:"version"`
+	Build         BuildInfo `json:"build"`
+	StartedAt     string    `json:"startedAt"` // RFC 3339 格式的启动时间
+	Uptime        string    `json:"uptime"`    // 如 "1h2m3s"
+	UptimeSeconds int64     `json:"uptimeSeconds"`
+}
+
+// MappingMatch 描述仓库命中的路径映射规则及本地路径
+type MappingMatch struct {
+	Index   int               `json:"index"`             // 命中的规则在 pathMappings 中的下标，-1 表示使用默认 cacheDir
+	Mapping *PathMapping      `json:"mapping,omitempty"` // 命中的规则
+	Path    string            `json:"path"`              // 本地仓库路径
+	Vars    map[string]string `json:"vars,omitempty"`    // 展开 localPath 时使用的变量
+}
+
+// MappingCandidate 描述一条规则对某个 URL 的匹配结果
+type MappingCandidate struct {
+	Index   int          `json:"index"`
+	Mapping *PathMapping `json:"mapping"`
+	Matched bool         `json:"matched"`
+	Path    string       `json:"path,omitempty"` // 规则匹配时展开的本地路径
+}
+
+// MappingTestRequest 是 POST /mappings/test 的请求
+type MappingTestRequest struct {
+	URL          string        `json:"url"`
+	PathMappings []PathMapping `json:"pathMappings,omitempty"` // 要测试的规则，省略时使用当前配置
+}
+
+// MappingTestResponse 是 POST /mappings/test 的响应
+type MappingTestResponse struct {
+	Status string             `json:"status"`
+	URL    *GitHubURLInfo     `json:"url"`
+	Match  MappingMatch       `json:"match"` // 实际使用的规则和路径
+	Rules  []MappingCandidate `json:"rules"` // 按匹配顺序排列的所有规则
+}
+
+// ConfigExplainResponse 是 GET /config?explain=1 的响应
+type ConfigExplainResponse struct {
+	Config  *Config           `json:"config"`
+	Layers  []*ConfigLayer    `json:"layers"`  // 按优先级从低到高排列的配置层
+	Origins map[string]string `json:"origins"` // 字段路径 → 提供该值的配置层
+}
+
+// ConfigUpdateResponse 是 PUT /config 和 PATCH /config 的响应
+type ConfigUpdateResponse struct {
+	Status string  `json:"status"`
+	Config *Config `json:"config"` // 更新后生效的配置
+}
+
+// ConfigErrorResponse 是配置相关接口的错误响应，errors 列出每个字段的问题
+type ConfigErrorResponse struct {
+	Status  string       `json:"status"`
+	Code    ErrorCode    `json:"code"`
+	Message string       `json:"message"`
+	Error   string       `json:"error"` // 与 message 相同，兼容旧版本客户端
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// ReuseConfig 控制是否复用已运行的编辑器实例
+type ReuseConfig struct {
+	Disabled    bool   `json:"disabled,omitempty"`    // 总是启动新实例
+	NvimSocket  string `json:"nvimSocket,omitempty"`  // Neovim 的 --listen 地址，为空时查找默认位置
+	EmacsSocket string `json:"emacsSocket,omitempty"` // emacsclient -s 使用的服务名或 socket 路径
+}
+
+// LaunchResult 描述 IDE 是如何被打开的
+type LaunchResult struct {
+	Mode   string `json:"mode"`             // reused 或 new
+	Detail string `json:"detail,omitempty"` // 使用的 socket，或未能复用的原因
+}
+
+// ResolveResponse 描述 /open 对同一请求会执行的操作
+type ResolveResponse struct {
+	Status       string         `json:"status"`
+	URL          *GitHubURLInfo `json:"url"`                // 解析出的 URL 信息
+	Mapping      *PathMapping   `json:"mapping,omitempty"`  // 匹配的路径映射规则，为空表示使用默认 cacheDir
+	RepoPath     string         `json:"repoPath"`           // 本地仓库路径
+	Exists       bool           `json:"exists"`             // 本地仓库是否已存在
+	Ref          string         `json:"ref,omitempty"`      // 要 checkout 的分支、tag 或 PR 分支
+	SHA          string         `json:"sha,omitempty"`      // ref 在本地解析出的 commit，仓库不存在或尚未获取时为空
+	RefError     string         `json:"refError,omitempty"` // ref 无法在本地解析的原因
+	TargetPath   string         `json:"targetPath"`         // 要打开的文件或目录
+	Line         int            `json:"line,omitempty"`
+	IDE          string         `json:"ide"`
+	FallbackFrom string         `json:"fallbackFrom,omitempty"` // 未安装而被替换的 IDE
+	IDERule      *IDERule       `json:"ideRule,omitempty"`      // 选择 IDE 时命中的规则
+	Language     string         `json:"language,omitempty"`     // 匹配规则时检测到的仓库语言
+	Command      []string       `json:"command,omitempty"`      // OpenInIDE 将执行的命令行
+	Dir          string         `json:"dir,omitempty"`          // 命令的工作目录
+	Hooks        []*Hook        `json:"hooks,omitempty"`        // 将要执行的 hook；仓库不存在时无法判断 markers
+	Target       string         `json:"target"`                 // 目标模式：local、devcontainer 或 ssh
+}
+
+// IDERule 定义请求未指定 IDE 时的选择规则
+// 规则按顺序匹配，设置的条件全部满足时使用该规则的 IDE
+type IDERule struct {
+	Name       string   `json:"name,omitempty"`       // 规则名称，便于在 /resolve 中识别
+	Repo       string   `json:"repo,omitempty"`       // owner/repo 模式，支持通配符，如 "myorg/*"；只写 owner 表示其所有仓库
+	Extensions []string `json:"extensions,omitempty"` // 打开文件的扩展名，如 ".go"
+	Language   string   `json:"language,omitempty"`   // 仓库主要语言，如 "go"、"python"（不区分大小写）
+	IDE        string   `json:"ide"`
+}
+
+// TerminalConfig 定义终端编辑器（nvim、vim、helix 等）在哪个终端中打开
+type TerminalConfig struct {
+	// Emulator 为终端名称，如 "kitty"、"gnome-terminal"、"tmux"、"macos-terminal"，为空时自动检测
+	Emulator string `json:"emulator,omitempty"`
+	// Command 为自定义命令模板，设置后忽略 Emulator
+	// $CMD 展开为编辑器命令的各个参数，$CMDLINE 展开为经过 shell 转义的单个字符串
+	Command []string `json:"command,omitempty"`
+	// TmuxSession 为 tmux 模式使用的会话名，默认 "github-browser"
+	TmuxSession string `json:"tmuxSession,omitempty"`
+}
+
+// URLHandlerConfig 控制 github-browser:// 链接可以打开哪些地址
+type URLHandlerConfig struct {
+	// AllowedHosts 为链接中 url 参数允许的主机，默认只允许 github.com
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+}
+
+const (
+	ErrCodeInvalidRequest  ErrorCode = "invalid_request"
+	ErrCodeInvalidURL      ErrorCode = "invalid_url"
+	ErrCodeRepoNotFound    ErrorCode = "repo_not_found"
+	ErrCodeRefNotFound     ErrorCode = "ref_not_found"
+	ErrCodePRNotFound      ErrorCode = "pr_not_found"
+	ErrCodeNotFound        ErrorCode = "not_found" // 历史记录、收藏等不存在
+	ErrCodeAuthRequired    ErrorCode = "auth_required"
+	ErrCodeForbidden       ErrorCode = "forbidden" // 网页发起的修改请求
+	ErrCodeRateLimited     ErrorCode = "rate_limited"
+	ErrCodeDirtyTree       ErrorCode = "dirty_tree"
+	ErrCodeUnsupportedIDE  ErrorCode = "unsupported_ide"
+	ErrCodeIDENotInstalled ErrorCode = "ide_not_installed"
+	ErrCodeNetwork         ErrorCode = "network_error"
+	ErrCodeGitFailed       ErrorCode = "git_failed"
+	ErrCodeServiceStopping ErrorCode = "service_stopping" // 服务正在停止，操作被取消
+	ErrCodeInternal        ErrorCode = "internal_error"
+)
+
+const (
+	URLTypeRepo URLType = "repository"
+	URLTypePR   URLType = "pull_request"
+)
@@ -1,110 +1,440 @@
 package main
 
 import (
-	"encoding/json"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 )
 
 // PathMapping 定义 GitHub 路径到本地目录的映射
 // Pattern 支持：
 //   - "owner" - 匹配特定用户/组织的所有仓库
 //   - "owner/repo" - 匹配特定仓库
+//   - "myorg/svc-*"、"*/infra-*" - 通配符，语法同 path.Match
 //   - "*" - 默认匹配所有
+//
+// 也可以用 Regex 代替 Pattern；匹配顺序见 pathResolver
 type PathMapping struct {
-	Pattern   string `json:"pattern"`   // GitHub 路径模式，如 "microsoft" 或 "microsoft/vscode"
-	LocalPath string `json:"localPath"` // 本地目录路径
+	Pattern   string       `json:"pattern,omitempty"`  // owner/repo 模式，支持通配符，如 "microsoft"、"microsoft/vscode"、"myorg/svc-*"、"*/infra-*"
+	Regex     string       `json:"regex,omitempty"`    // 匹配 owner/repo 的正则表达式，与 pattern 二选一，命名分组可以在 localPath 中引用
+	Host      string       `json:"host,omitempty"`     // 只匹配该主机上的仓库，支持通配符，为空匹配所有主机
+	Priority  int          `json:"priority,omitempty"` // 数值大的先匹配
+	LocalPath string       `json:"localPath"`          // 本地目录路径，支持 {host}、{owner}、{repo}、{ref} 占位符，相对路径相对于 cacheDir
+	Git       *GitStrategy `json:"git,omitempty"`      // 覆盖全局的克隆/拉取策略
+}
+
+// GitStrategy 定义克隆和拉取仓库的策略
+// 未设置的字段沿用上一层（全局配置或默认值）
+type GitStrategy struct {
+	Filter       string   `json:"filter,omitempty"`       // partial clone 过滤器，如 "blob:none"、"tree:0"；"none" 表示完整克隆
+	Depth        int      `json:"depth,omitempty"`        // 浅克隆深度，0 沿用上一层，DepthFull（-1）表示完整历史
+	SingleBranch *bool    `json:"singleBranch,omitempty"` // 只克隆默认分支
+	Tags         *bool    `json:"tags,omitempty"`         // 是否获取 tag
+	Refspecs     []string `json:"refspecs,omitempty"`     // fetch 时使用的 refspec，为空则获取所有远程
+	Submodules   string   `json:"submodules,omitempty"`   // 子模块策略："recursive" 或 "off"
+	LFS          string   `json:"lfs,omitempty"`          // LFS 策略："path" 仅拉取打开的路径、"all" 或 "off"
+}
+
+// DepthFull 表示获取完整历史，用于在映射中覆盖全局的浅克隆深度
+const DepthFull = -1
+
+const (
+	SubmodulesRecursive = "recursive"
+	SubmodulesOff       = "off"
+
+	LFSPath = "path"
+	LFSAll  = "all"
+	LFSOff  = "off"
+)
+
+// CustomIDE 定义用户自定义的 IDE 启动方式，与内置 IDE 同名时覆盖内置配置
+// 参数、环境变量和工作目录支持占位符：$PATH、$LINE、$COLUMN、$REPO、$FILE
+type CustomIDE struct {
+	Command  string            `json:"command"`            // 可执行文件或脚本
+	Args     []string          `json:"args"`               // 参数模板
+	LineArgs []string          `json:"lineArgs,omitempty"` // 有行号时替代 args 使用的参数模板
+	Env      map[string]string `json:"env,omitempty"`      // 额外的环境变量
+	Dir      string            `json:"dir,omitempty"`      // 工作目录，如 "$REPO"
+	Terminal bool              `json:"terminal,omitempty"` // 是否为需要在终端中运行的编辑器
 }
 
 type Config struct {
-	Port         int           `json:"port"`
-	DefaultIDE   string        `json:"defaultIDE"`
-	GitHubToken  string        `json:"githubToken"`
-	CacheDir     string        `json:"cacheDir"`
-	PathMappings []PathMapping `json:"pathMappings,omitempty"` // 路径映射规则
+	Schema          string                  `json:"$schema,omitempty"` // 编辑器补全使用的 JSON Schema，见 GET /config/schema
+	Version         int                     `json:"version"`           // 配置文件格式版本，旧版本在加载时自动迁移
+	Include         []string                `json:"include,omitempty"` // 引用的团队配置文件，只在配置文件中使用
+	Port            int                     `json:"port"`
+	DefaultIDE      string                  `json:"defaultIDE"`
+	GitHubToken     string                  `json:"githubToken"`
+	CacheDir        string                  `json:"cacheDir"`
+	GitBackend      string                  `json:"gitBackend,omitempty"`      // git 实现："exec"（默认）或 "go-git"
+	Git             *GitStrategy            `json:"git,omitempty"`             // 全局克隆/拉取策略
+	PathMappings    []PathMapping           `json:"pathMappings,omitempty"`    // 路径映射规则
+	CustomIDEs      map[string]CustomIDE    `json:"customIDEs,omitempty"`      // 自定义 IDE
+	IDERules        []IDERule               `json:"ideRules,omitempty"`        // 请求未指定 IDE 时的选择规则
+	IDEPreference   []string                `json:"idePreference,omitempty"`   // 请求的 IDE 未安装时按顺序尝试的 IDE
+	Terminal        *TerminalConfig         `json:"terminal,omitempty"`        // 终端编辑器使用的终端
+	Reuse           *ReuseConfig            `json:"reuse,omitempty"`           // 复用已运行的编辑器实例
+	Workspaces      map[string]WorkspaceSet `json:"workspaces,omitempty"`      // 可通过名称批量打开的仓库集合
+	Hooks           []Hook                  `json:"hooks,omitempty"`           // clone/checkout 之后、启动 IDE 之前执行的初始化命令
+	DevContainer    bool                    `json:"devContainer,omitempty"`    // 自动在 dev container 中打开含 devcontainer.json 的仓库（仅 VS Code 系列）
+	Remote          *RemoteConfig           `json:"remote,omitempty"`          // 远程开发主机
+	URLHandler      *URLHandlerConfig       `json:"urlHandler,omitempty"`      // github-browser:// 链接允许打开的主机
+	Log             *LogConfig              `json:"log,omitempty"`             // 日志级别、格式和日志文件
+	History         *HistoryConfig          `json:"history,omitempty"`         // 打开历史的保留策略
+	ShutdownTimeout string                  `json:"shutdownTimeout,omitempty"` // 停止服务时等待进行中的请求的时间，如 "1m"，默认 30 秒
+	Listen          *ListenConfig           `json:"listen,omitempty"`          // 监听 TCP 端口和/或 Unix socket
+	AllowedOrigins  []string                `json:"allowedOrigins,omitempty"`  // 接受跨域请求的来源，如 "chrome-extension://<扩展 ID>"，为空时接受所有浏览器扩展
+}
+
+// originPattern 匹配浏览器请求头中的 Origin，如 chrome-extension://abcdef 或 http://localhost:3000
+var originPattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*://[^/\s]+$`)
+
+// DefaultGitStrategy 返回默认策略：blobless 克隆，获取所有分支和 tag，
+// 递归初始化子模块，只拉取打开路径下的 LFS 文件
+func DefaultGitStrategy() GitStrategy {
+	singleBranch, tags := false, true
+	return GitStrategy{
+		Filter:       "blob:none",
+		SingleBranch: &singleBranch,
+		Tags:         &tags,
+		Submodules:   SubmodulesRecursive,
+		LFS:          LFSPath,
+	}
+}
+
+// cloneDepth 返回传给 git 的深度，0 表示完整历史
+func (s GitStrategy) cloneDepth() int {
+	if s.Depth > 0 {
+		return s.Depth
+	}
+	return 0
+}
+
+// merge 用 o 中已设置的字段覆盖 s
+func (s GitStrategy) merge(o *GitStrategy) GitStrategy {
+	if o == nil {
+		return s
+	}
+	if o.Filter != "" {
+		s.Filter = o.Filter
+	}
+	if o.Depth != 0 {
+		s.Depth = o.Depth
+	}
+	if o.SingleBranch != nil {
+		s.SingleBranch = o.SingleBranch
+	}
+	if o.Tags != nil {
+		s.Tags = o.Tags
+	}
+	if len(o.Refspecs) > 0 {
+		s.Refspecs = o.Refspecs
+	}
+	if o.Submodules != "" {
+		s.Submodules = o.Submodules
+	}
+	if o.LFS != "" {
+		s.LFS = o.LFS
+	}
+	return s
 }
 
 func DefaultConfig() *Config {
 	return &Config{
+		Version:    CurrentConfigVersion,
 		Port:       DefaultPort,
 		DefaultIDE: "code", // VS Code
 		CacheDir:   filepath.Join(os.Getenv("HOME"), DefaultCacheDir),
 	}
 }
 
-func LoadConfig() (*Config, error) {
-	configPath := filepath.Join(os.Getenv("HOME"), ".github-browser", "config.json")
-
-	// 如果配置文件不存在，创建默认配置
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		config := DefaultConfig()
-		if err := SaveConfig(config); err != nil {
-			return nil, err
-		}
-		return config, nil
-	}
+// ConfigPath 返回配置文件路径
+func ConfigPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".github-browser", "config.json")
+}
 
-	// 读取配置文件
-	data, err := os.ReadFile(configPath)
+// LoadConfig 加载合并了系统、团队、用户配置和环境变量的配置
+func LoadConfig() (*Config, error) {
+	lc, err := loadLayeredConfig()
 	if err != nil {
 		return nil, err
 	}
+	return lc.config, nil
+}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, err
+// SaveConfig 写入用户配置文件，只保存与默认值、系统和团队配置不同的部分
+func SaveConfig(config *Config) error {
+	layers, _, err := readConfigLayers()
+	if err != nil {
+		return err
+	}
+	user, err := userLayerDiff(config, layers)
+	if err != nil {
+		return err
 	}
+	return writeUserConfig(user)
+}
 
-	return &config, nil
+// writeConfigFile 先写临时文件再重命名，监听文件的服务不会读到写了一半的内容
+func writeConfigFile(path string, data []byte) error {
+	return writeFileAtomic(path, data, 0644)
 }
 
-func SaveConfig(config *Config) error {
-	configDir := filepath.Join(os.Getenv("HOME"), ".github-browser")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+// writeFileAtomic 在同一目录下写临时文件后重命名为 path，读取方不会读到写了一半的内容
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 
-	configPath := filepath.Join(configDir, "config.json")
-	data, err := json.MarshalIndent(config, "", "  ")
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// placeholderPattern 匹配模板中的 $NAME 占位符
+var placeholderPattern = regexp.MustCompile(`\$[A-Z_]+`)
 
-	return os.WriteFile(configPath, data, 0644)
+// Validate 检查配置是否有效，返回的 *ValidationError 包含所有字段的错误
+func (c *Config) Validate() error {
+	v := &ValidationError{}
+	if c.Version < 0 || c.Version > CurrentConfigVersion {
+		v.add("version", fmt.Errorf("unsupported version %d, newest supported is %d", c.Version, CurrentConfigVersion))
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		v.add("port", fmt.Errorf("must be between 1 and 65535"))
+	}
+	if c.DefaultIDE != "" {
+		if _, ok := lookupIDE(c.DefaultIDE, c.CustomIDEs); !ok {
+			v.add("defaultIDE", fmt.Errorf("unknown IDE %s", c.DefaultIDE))
+		}
+	}
+	v.add("cacheDir", validateLocalPath(c.CacheDir))
+	switch c.GitBackend {
+	case "", GitBackendExec, GitBackendGoGit:
+	default:
+		v.add("gitBackend", fmt.Errorf("must be %s or %s", GitBackendExec, GitBackendGoGit))
+	}
+	if c.Git != nil {
+		v.add("git", c.Git.validate())
+	}
+	patterns := map[string]bool{}
+	for i, m := range c.PathMappings {
+		field := fmt.Sprintf("pathMappings[%d]", i)
+		v.add(field, m.validate())
+		if key := m.key(); patterns[key] {
+			v.add(field, fmt.Errorf("duplicate mapping for host %q, pattern %q and regex %q", m.Host, m.Pattern, m.Regex))
+		} else {
+			patterns[key] = true
+		}
+	}
+	for i, rule := range c.IDERules {
+		v.add(fmt.Sprintf("ideRules[%d]", i), rule.validate(c.CustomIDEs))
+	}
+	for i, name := range c.IDEPreference {
+		if _, ok := lookupIDE(name, c.CustomIDEs); !ok {
+			v.add(fmt.Sprintf("idePreference[%d]", i), fmt.Errorf("unknown IDE %s", name))
+		}
+	}
+	for name, ide := range c.CustomIDEs {
+		if !ideNamePattern.MatchString(name) {
+			v.add("customIDEs."+name, fmt.Errorf("invalid IDE name, use letters, digits, '.', '_' or '-'"))
+		}
+		v.add("customIDEs."+name, ide.validate())
+	}
+	for i, hook := range c.Hooks {
+		v.add(fmt.Sprintf("hooks[%d]", i), hook.validate())
+	}
+	for name, ws := range c.Workspaces {
+		v.add("workspaces."+name, ws.validate(name, c.CustomIDEs))
+	}
+	if c.Remote != nil {
+		v.add("remote", c.Remote.validate())
+	}
+	if c.URLHandler != nil {
+		v.add("urlHandler", c.URLHandler.validate())
+	}
+	if c.Log != nil {
+		v.add("log", c.Log.validate())
+	}
+	if c.History != nil {
+		v.add("history", c.History.validate())
+	}
+	if c.Listen != nil {
+		v.add("listen", c.Listen.validate())
+	}
+	for i, origin := range c.AllowedOrigins {
+		if !originPattern.MatchString(origin) {
+			v.add(fmt.Sprintf("allowedOrigins[%d]", i), fmt.Errorf("invalid origin %q, use scheme://host such as chrome-extension://<id>", origin))
+		}
+	}
+	if c.ShutdownTimeout != "" {
+		if d, err := time.ParseDuration(c.ShutdownTimeout); err != nil || d <= 0 {
+			v.add("shutdownTimeout", fmt.Errorf("invalid duration %q, use e.g. \"30s\" or \"2m\"", c.ShutdownTimeout))
+		}
+	}
+	if t := c.Terminal; t != nil && len(t.Command) == 0 && t.Emulator != "" {
+		if _, ok := terminalTemplates[t.Emulator]; !ok && t.Emulator != TerminalTmux {
+			v.add("terminal.emulator", fmt.Errorf("unsupported terminal %s", t.Emulator))
+		}
+	}
+	return v.err()
 }
 
-// GetRepoPath 根据 owner 和 repo 返回本地仓库路径
-// 按优先级匹配：owner/repo > owner > * > 默认 cacheDir
-func (c *Config) GetRepoPath(owner, repo string) string {
-	exactMatch := owner + "/" + repo
+// mappingPatternRegexp 匹配 pathMappings 的 pattern："owner" 或 "owner/repo"，各部分可以包含通配符
+var mappingPatternRegexp = regexp.MustCompile(`^[A-Za-z0-9_.*?\[\]^-]+(/[A-Za-z0-9_.*?\[\]^-]+)?$`)
+
+// validate 检查路径映射是否有效
+func (m PathMapping) validate() error {
+	switch {
+	case m.Pattern == "" && m.Regex == "":
+		return fmt.Errorf("pattern: one of pattern or regex is required")
+	case m.Pattern != "" && m.Regex != "":
+		return fmt.Errorf("regex: cannot be used together with pattern")
+	}
 
-	// 优先匹配 owner/repo
-	for _, m := range c.PathMappings {
-		if m.Pattern == exactMatch {
-			return expandPath(m.LocalPath)
+	vars := map[string]bool{}
+	for _, name := range mappingTemplateVars {
+		vars[name] = true
+	}
+	if m.Pattern != "" {
+		if _, err := path.Match(m.Pattern, ""); err != nil || !mappingPatternRegexp.MatchString(m.Pattern) {
+			return fmt.Errorf("pattern: must be \"owner\" or \"owner/repo\" with optional wildcards, got %q", m.Pattern)
+		}
+	} else {
+		re, err := regexp.Compile(m.Regex)
+		if err != nil {
+			return fmt.Errorf("regex: %v", err)
+		}
+		for _, name := range re.SubexpNames() {
+			if name == "" {
+				continue
+			}
+			if vars[name] {
+				return fmt.Errorf("regex: group name %q conflicts with the built-in placeholder {%s}", name, name)
+			}
+			vars[name] = true
+		}
+	}
+	if m.Host != "" {
+		if _, err := path.Match(m.Host, ""); err != nil || strings.ContainsAny(m.Host, "/: ") {
+			return fmt.Errorf("host: invalid host pattern %q", m.Host)
 		}
 	}
 
-	// 其次匹配 owner
-	for _, m := range c.PathMappings {
-		if m.Pattern == owner {
-			return filepath.Join(expandPath(m.LocalPath), repo)
+	if m.LocalPath == "" {
+		return fmt.Errorf("localPath: is required")
+	}
+	for _, placeholder := range templateVarPattern.FindAllStringSubmatch(m.LocalPath, -1) {
+		if !vars[placeholder[1]] {
+			return fmt.Errorf("localPath: unknown placeholder {%s}", placeholder[1])
 		}
 	}
+	if m.Git != nil {
+		if err := m.Git.validate(); err != nil {
+			return fmt.Errorf("git.%v", err)
+		}
+	}
+	return nil
+}
+
+// key 返回区分路径映射规则的键，同一配置中不能重复
+func (m PathMapping) key() string {
+	return strings.ToLower(m.Host) + "\x00" + m.Pattern + "\x00" + m.Regex
+}
+
+// gitFilterPattern 匹配 git clone --filter 支持的过滤器
+var gitFilterPattern = regexp.MustCompile(`^(none|blob:none|blob:limit=\d+[kmg]?|tree:\d+)$`)
+
+// validate 检查克隆/拉取策略是否有效
+func (s GitStrategy) validate() error {
+	if s.Filter != "" && !gitFilterPattern.MatchString(s.Filter) {
+		return fmt.Errorf("filter: unsupported filter %q", s.Filter)
+	}
+	if s.Depth < DepthFull {
+		return fmt.Errorf("depth: must be a positive number, or -1 for full history")
+	}
+	switch s.Submodules {
+	case "", SubmodulesRecursive, SubmodulesOff:
+	default:
+		return fmt.Errorf("submodules: must be %s or %s", SubmodulesRecursive, SubmodulesOff)
+	}
+	switch s.LFS {
+	case "", LFSPath, LFSAll, LFSOff:
+	default:
+		return fmt.Errorf("lfs: must be %s, %s or %s", LFSPath, LFSAll, LFSOff)
+	}
+	return nil
+}
+
+// validateLocalPath 检查本地目录配置为绝对路径或以 ~ 开头
+func validateLocalPath(path string) error {
+	if path == "" || path == "~" || strings.HasPrefix(path, "~/") || filepath.IsAbs(path) {
+		return nil
+	}
+	return fmt.Errorf("must be an absolute path or start with ~/")
+}
+
+func (ide CustomIDE) validate() error {
+	if strings.TrimSpace(ide.Command) == "" {
+		return fmt.Errorf("command is required")
+	}
 
-	// 匹配通配符 *
-	for _, m := range c.PathMappings {
-		if m.Pattern == "*" {
-			return filepath.Join(expandPath(m.LocalPath), owner+"-"+repo)
+	check := func(field, tmpl string, allowLine bool) error {
+		for _, p := range placeholderPattern.FindAllString(tmpl, -1) {
+			known := false
+			for _, allowed := range ideTemplatePlaceholders {
+				if p == allowed {
+					known = true
+					break
+				}
+			}
+			if !known {
+				return fmt.Errorf("%s: unknown placeholder %s", field, p)
+			}
+			if !allowLine && (p == "$LINE" || p == "$COLUMN") {
+				return fmt.Errorf("%s: %s is only allowed in lineArgs", field, p)
+			}
 		}
+		return nil
 	}
 
-	// 默认使用 cacheDir
-	cacheDir := c.CacheDir
-	if cacheDir == "" {
-		cacheDir = filepath.Join(os.Getenv("HOME"), DefaultCacheDir)
+	// 没有 lineArgs 时 args 总会被使用，不能依赖行号
+	for i, arg := range ide.Args {
+		if err := check(fmt.Sprintf("args[%d]", i), arg, false); err != nil {
+			return err
+		}
+	}
+	for i, arg := range ide.LineArgs {
+		if err := check(fmt.Sprintf("lineArgs[%d]", i), arg, true); err != nil {
+			return err
+		}
+	}
+	for k, v := range ide.Env {
+		if k == "" || strings.Contains(k, "=") {
+			return fmt.Errorf("env: invalid variable name %q", k)
+		}
+		if err := check("env."+k, v, false); err != nil {
+			return err
+		}
 	}
-	return filepath.Join(cacheDir, owner+"-"+repo)
+	return check("dir", ide.Dir, false)
 }
 
 // expandPath 展开路径中的 ~ 为 home 目录
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CurrentConfigVersion 是当前配置文件格式的版本
+const CurrentConfigVersion = 1
+
+// configMigrations[i] 把版本 i 的配置升级到版本 i+1
+// 迁移作用于解析为 Config 之前的原始 JSON，可以处理已改名或删除的字段
+var configMigrations = []func(raw map[string]interface{}) error{
+	// 0 → 1：加入 version 字段之前的配置，字段与版本 1 相同
+	func(raw map[string]interface{}) error { return nil },
+}
+
+// configSchema 是 Config 的 JSON Schema，由 GET /config/schema 返回
+//
+//go:embed config.schema.json
+var configSchema []byte
+
+// FieldError 描述一个配置字段的错误
+type FieldError struct {
+	Field   string `json:"field"` // 字段路径，如 pathMappings[0].localPath
+	Message string `json:"message"`
+	Source  string `json:"source,omitempty"` // 提供该值的配置层，如 user:/home/me/.github-browser/config.json
+}
+
+// ValidationError 汇总配置中所有字段的错误
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		messages[i] = fe.Field + ": " + fe.Message
+		if fe.Source != "" {
+			messages[i] += " (" + fe.Source + ")"
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// fieldPrefixPattern 匹配子配置 validate 返回的 "字段: 说明" 格式的错误
+var fieldPrefixPattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_.]*(?:\[\d+\])*): (.+)$`)
+
+// add 记录字段错误，err 为 nil 时忽略
+// 子配置返回的 "stage: ..." 等错误会拼接到字段路径上
+func (e *ValidationError) add(field string, err error) {
+	if err == nil {
+		return
+	}
+	message := err.Error()
+	if m := fieldPrefixPattern.FindStringSubmatch(message); m != nil {
+		field, message = joinField(field, m[1]), m[2]
+	}
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: message})
+}
+
+// addFrom 记录某个配置层中的错误，字段错误标注来源
+func (e *ValidationError) addFrom(layer *configLayer, err error) {
+	errs := fieldErrors(err)
+	if errs == nil {
+		e.add("", err)
+		errs = e.Errors[len(e.Errors)-1:]
+		e.Errors = e.Errors[:len(e.Errors)-1]
+	}
+	for _, fe := range errs {
+		fe.Source = layer.origin()
+		e.Errors = append(e.Errors, fe)
+	}
+}
+
+// err 没有错误时返回 nil，否则按字段排序后返回自身
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(e.Errors, func(i, j int) bool { return e.Errors[i].Field < e.Errors[j].Field })
+	return e
+}
+
+func joinField(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	return parent + "." + child
+}
+
+// fieldErrors 返回错误中的字段错误，非校验错误时返回 nil
+func fieldErrors(err error) []FieldError {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve.Errors
+	}
+	return nil
+}
+
+// parseConfig 解析配置文件内容：迁移旧版本、拒绝未知字段并校验
+// 返回文件原来的版本，调用方据此决定是否写回迁移后的配置
+func parseConfig(data []byte) (*Config, int, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, err
+	}
+	if raw == nil {
+		return nil, 0, fmt.Errorf("config must be a JSON object")
+	}
+
+	version, err := migrateConfig(raw)
+	if err != nil {
+		return nil, version, err
+	}
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, version, err
+	}
+	config, err := decodeConfig(migrated)
+	if err != nil {
+		// 有未知字段时仍校验其他字段，一次报告所有问题
+		var lenient Config
+		if ve, ok := err.(*ValidationError); ok && json.Unmarshal(migrated, &lenient) == nil {
+			if other := fieldErrors(lenient.Validate()); other != nil {
+				ve.Errors = append(ve.Errors, other...)
+			}
+		}
+		return nil, version, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, version, err
+	}
+	return config, version, nil
+}
+
+// migrateConfig 把原始配置升级到当前版本，返回原来的版本
+func migrateConfig(raw map[string]interface{}) (int, error) {
+	version := 0
+	if v, ok := raw["version"]; ok {
+		f, isNumber := v.(float64)
+		if !isNumber || f < 0 || f != math.Trunc(f) {
+			return 0, &ValidationError{[]FieldError{{Field: "version", Message: "must be a non-negative integer"}}}
+		}
+		version = int(f)
+	}
+	if version > CurrentConfigVersion {
+		return version, &ValidationError{[]FieldError{{
+			Field:   "version",
+			Message: fmt.Sprintf("config version %d is newer than supported version %d, upgrade github-browser-service", version, CurrentConfigVersion),
+		}}}
+	}
+
+	for v := version; v < CurrentConfigVersion; v++ {
+		if err := configMigrations[v](raw); err != nil {
+			return version, fmt.Errorf("failed to migrate config from version %d: %w", v, err)
+		}
+	}
+	raw["version"] = CurrentConfigVersion
+	return version, nil
+}
+
+// decodeConfig 解析配置，拒绝未知字段，类型错误和未知字段转换为字段错误
+func decodeConfig(data []byte) (*Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &ValidationError{[]FieldError{{
+				Field:   typeErr.Field,
+				Message: fmt.Sprintf("must be %s, got %s", jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value),
+			}}}
+		}
+		// encoding/json 对未知字段只返回 `json: unknown field "name"`
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return nil, &ValidationError{[]FieldError{{Field: strings.Trim(name, `"`), Message: "unknown field"}}}
+		}
+		return nil, err
+	}
+	return &config, nil
+}
+
+// jsonTypeName 把 Go 类型种类转换为 JSON 中的类型名称
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "int", "int64", "uint32", "float64":
+		return "a number"
+	case "bool":
+		return "a boolean"
+	case "string":
+		return "a string"
+	case "slice":
+		return "an array"
+	case "map", "struct", "ptr":
+		return "an object"
+	}
+	return kind
+}
+
+// mergePatch 按 RFC 7386 把 patch 合并到 target：null 删除字段，对象递归合并，其他值直接替换
+func mergePatch(target, patch map[string]interface{}) {
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if patchObject, ok := value.(map[string]interface{}); ok {
+			targetObject, ok := target[key].(map[string]interface{})
+			if !ok {
+				targetObject = map[string]interface{}{}
+			}
+			mergePatch(targetObject, patchObject)
+			target[key] = targetObject
+			continue
+		}
+		target[key] = value
+	}
+}
+
+// configToMap 把配置转换为通用的 JSON 对象，便于合并
+func configToMap(config *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	return raw, json.Unmarshal(data, &raw)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TargetLocal        = "local"        // 在本机打开
+	TargetDevContainer = "devcontainer" // 在仓库的 dev container 中打开
+	TargetSSH          = "ssh"          // 在远程主机上克隆并打开
+
+	remoteVSCode  = "vscode"  // 通过 vscode-remote:// URI 打开
+	remoteGateway = "gateway" // 通过 JetBrains Gateway 打开
+
+	defaultRemoteCacheDir = "~/.github-browser/repos"
+)
+
+// RemoteConfig 定义远程开发主机，仓库会通过 ssh 在该主机上克隆和更新
+type RemoteConfig struct {
+	Host     string   `json:"host"`               // ssh 主机，可以是 ~/.ssh/config 中的别名或 user@host
+	Port     int      `json:"port,omitempty"`     // JetBrains Gateway 连接使用的端口，默认 22
+	CacheDir string   `json:"cacheDir,omitempty"` // 远程主机上的缓存目录，默认 ~/.github-browser/repos
+	Repos    []string `json:"repos,omitempty"`    // 未指定 target 时默认在远程主机上打开的仓库，owner/repo 模式
+}
+
+// validate 检查远程主机配置是否有效
+func (r *RemoteConfig) validate() error {
+	if r.Host == "" || strings.HasPrefix(r.Host, "-") || strings.ContainsAny(r.Host, " \t/") {
+		return fmt.Errorf("host: invalid ssh host %q", r.Host)
+	}
+	if r.Port < 0 || r.Port > 65535 {
+		return fmt.Errorf("port: invalid port %d", r.Port)
+	}
+	for i, pattern := range r.Repos {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("repos[%d]: invalid pattern %q", i, pattern)
+		}
+	}
+	return nil
+}
+
+// repoHost 描述在哪里准备仓库：本机或 ssh 远程主机
+type repoHost struct {
+	git      GitClient
+	repoPath string
+	exists   func() bool
+	remove   func() error // 删除克隆失败时留下的目录，为 nil 时不处理
+}
+
+// discardClone 删除克隆失败（如服务停止时被强制结束）留下的不完整目录
+func (h *repoHost) discardClone() {
+	if h.remove != nil && h.exists() {
+		h.remove()
+	}
+}
+
+// localHost 返回在本机准备仓库的 repoHost
+func (s *Service) localHost(info *GitHubURLInfo) *repoHost {
+	repoPath := s.paths.resolve(info).Path
+	return &repoHost{
+		git:      s.gitClient,
+		repoPath: repoPath,
+		exists: func() bool {
+			_, err := os.Stat(repoPath)
+			return err == nil
+		},
+		remove: func() error { return os.RemoveAll(repoPath) },
+	}
+}
+
+// sshHost 返回在配置的远程主机上准备仓库的 repoHost，git 命令通过 ssh 执行
+func (s *Service) sshHost(info *GitHubURLInfo) (*repoHost, error) {
+	remote := s.config.Remote
+	if remote == nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "remote host is not configured", nil)
+	}
+	cacheDir, err := remoteCacheDir(remote)
+	if err != nil {
+		return nil, err
+	}
+	repoPath := path.Join(cacheDir, info.Owner+"-"+info.Repo)
+	return &repoHost{
+		git:      &ExecGitClient{cacheDir: cacheDir, sshHost: remote.Host, log: s.log},
+		repoPath: repoPath,
+		exists: func() bool {
+			return sshCommand(remote.Host, "test -d "+shellJoin([]string{repoPath})).Run() == nil
+		},
+	}, nil
+}
+
+// remoteHomes 缓存远程主机的 $HOME，避免每次请求都建立 ssh 连接
+var remoteHomes sync.Map
+
+// remoteCacheDir 返回远程主机上缓存目录的绝对路径，~ 会被展开为远程用户的 $HOME
+func remoteCacheDir(remote *RemoteConfig) (string, error) {
+	dir := remote.CacheDir
+	if dir == "" {
+		dir = defaultRemoteCacheDir
+	}
+	if dir != "~" && !strings.HasPrefix(dir, "~/") {
+		return dir, nil
+	}
+
+	home, ok := remoteHomes.Load(remote.Host)
+	if !ok {
+		output, err := sshCommand(remote.Host, `printf %s "$HOME"`).CombinedOutput()
+		if err != nil {
+			return "", classifyGitError("ssh "+remote.Host, err, output)
+		}
+		home = strings.TrimSpace(string(output))
+		remoteHomes.Store(remote.Host, home)
+	}
+	return path.Join(home.(string), strings.TrimPrefix(dir, "~")), nil
+}
+
+// sshCommand 创建在远程主机上执行 shell 命令行的 ssh 命令
+// BatchMode 避免需要输入密码时请求一直挂起；服务停止时命令会被取消
+func sshCommand(host, commandLine string) *exec.Cmd {
+	cmd := exec.CommandContext(jobsCtx, "ssh", "-o", "BatchMode=yes", host, commandLine)
+	setTerminateOnCancel(cmd)
+	return cmd
+}
+
+// sshGitCommand 创建在远程主机的 dir 目录中执行的 git 命令
+func sshGitCommand(host, dir string, args ...string) *exec.Cmd {
+	line := "GIT_TERMINAL_PROMPT=0 git " + shellJoin(args)
+	if dir != "" {
+		line = "cd " + shellJoin([]string{dir}) + " && " + line
+	}
+	return sshCommand(host, line)
+}
+
+// requestedTargetMode 根据请求和配置确定准备仓库的位置
+// dev container 需要在仓库准备好之后才能判断，这里只区分本机和远程主机
+func (s *Service) requestedTargetMode(requested string, info *GitHubURLInfo) (string, error) {
+	switch requested {
+	case TargetLocal, TargetDevContainer:
+		return requested, nil
+	case TargetSSH:
+		if s.config.Remote == nil {
+			return "", newServiceError(ErrCodeInvalidRequest, "target ssh requires remote.host in config", nil)
+		}
+		return TargetSSH, nil
+	case "":
+		if remote := s.config.Remote; remote != nil {
+			for _, pattern := range remote.Repos {
+				if matchRepoPattern(pattern, info.Owner, info.Repo) {
+					return TargetSSH, nil
+				}
+			}
+		}
+		return TargetLocal, nil
+	default:
+		return "", newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("unsupported target: %s", requested), nil)
+	}
+}
+
+// prepareHost 返回按目标模式准备仓库的位置
+func (s *Service) prepareHost(mode string, info *GitHubURLInfo) (*repoHost, error) {
+	if mode == TargetSSH {
+		return s.sshHost(info)
+	}
+	return s.localHost(info), nil
+}
+
+// applyDevContainer 在仓库准备好后决定是否在 dev container 中打开
+// 未指定 target 时，开启 devContainer 且 IDE 支持的情况下自动使用 dev container
+func (s *Service) applyDevContainer(requested string, target *OpenTarget, ideName string) error {
+	switch {
+	case target.Mode == TargetDevContainer:
+		if devContainerConfig(target.RepoPath) == "" {
+			return newServiceError(ErrCodeInvalidRequest, "repository has no .devcontainer/devcontainer.json", nil)
+		}
+	case requested == "" && target.Mode == TargetLocal && s.config.DevContainer:
+		ide, ok := lookupIDE(ideName, s.config.CustomIDEs)
+		if ok && ide.remote == remoteVSCode && devContainerConfig(target.RepoPath) != "" {
+			target.Mode = TargetDevContainer
+		}
+	}
+	return nil
+}
+
+// devContainerConfig 返回仓库的 dev container 配置文件，不存在时返回空
+func devContainerConfig(repoPath string) string {
+	for _, name := range []string{filepath.Join(".devcontainer", "devcontainer.json"), ".devcontainer.json"} {
+		file := filepath.Join(repoPath, name)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// workspaceFolderPattern 匹配 devcontainer.json 中的 workspaceFolder（文件允许注释，不做完整解析）
+var workspaceFolderPattern = regexp.MustCompile(`"workspaceFolder"\s*:\s*"([^"]+)"`)
+
+// devContainerWorkspace 返回仓库在容器中的路径，默认 /workspaces/<目录名>
+func devContainerWorkspace(repoPath string) string {
+	base := filepath.Base(repoPath)
+	if data, err := os.ReadFile(devContainerConfig(repoPath)); err == nil {
+		if m := workspaceFolderPattern.FindSubmatch(data); m != nil {
+			return strings.ReplaceAll(string(m[1]), "${localWorkspaceFolderBasename}", base)
+		}
+	}
+	return "/workspaces/" + base
+}
+
+// buildRemoteIDECommand 构造在 dev container 或远程主机中打开仓库的命令
+// VS Code 系列使用 vscode-remote:// URI，JetBrains IDE 通过 Gateway 连接远程主机
+func buildRemoteIDECommand(ideName string, ide ideConfig, target OpenTarget, config *Config) (*exec.Cmd, error) {
+	file := ""
+	if rel, err := filepath.Rel(target.RepoPath, target.Path); err == nil && rel != "." {
+		file = filepath.ToSlash(rel)
+	}
+
+	switch {
+	case ide.remote == remoteVSCode:
+		var authority, folder string
+		if target.Mode == TargetDevContainer {
+			authority = "dev-container+" + hex.EncodeToString([]byte(target.RepoPath))
+			folder = devContainerWorkspace(target.RepoPath)
+		} else {
+			authority = "ssh-remote+" + config.Remote.Host
+			folder = target.RepoPath
+		}
+		args := []string{"--folder-uri", "vscode-remote://" + authority + folder}
+		if file != "" {
+			args = append(args, "--file-uri", "vscode-remote://"+authority+path.Join(folder, file))
+		}
+		return exec.Command(resolveIDECommand(ide.cmd), args...), nil
+
+	case ide.remote == remoteGateway && target.Mode == TargetSSH:
+		return openURLCommand(gatewayURL(config.Remote, target.RepoPath)), nil
+	}
+
+	return nil, newServiceError(ErrCodeUnsupportedIDE, fmt.Sprintf("%s cannot open %s targets", ideName, target.Mode), nil)
+}
+
+// gatewayURL 构造 JetBrains Gateway 的 ssh 连接链接
+func gatewayURL(remote *RemoteConfig, projectPath string) string {
+	user, host, ok := strings.Cut(remote.Host, "@")
+	if !ok {
+		user, host = "", remote.Host
+	}
+	port := remote.Port
+	if port == 0 {
+		port = 22
+	}
+
+	params := url.Values{}
+	params.Set("type", "ssh")
+	params.Set("deploy", "false")
+	params.Set("host", host)
+	params.Set("port", strconv.Itoa(port))
+	params.Set("projectPath", projectPath)
+	if user != "" {
+		params.Set("user", user)
+	}
+	return "jetbrains-gateway://connect#" + params.Encode()
+}
+
+// openURLCommand 使用系统默认程序打开 URL
+func openURLCommand(u string) *exec.Cmd {
+	if runtime.GOOS == "darwin" {
+		return exec.Command("open", u)
+	}
+	return exec.Command("xdg-open", u)
+}
@@ -2,117 +2,463 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 )
 
-type GitClient struct {
-	cacheDir string
+// fullSHAPattern 匹配完整的 commit SHA
+var fullSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+const (
+	GitBackendExec  = "exec"   // 调用系统 git 命令
+	GitBackendGoGit = "go-git" // 纯 Go 实现，不依赖 git 可执行文件
+)
+
+// GitClient 封装服务使用的 git 操作
+type GitClient interface {
+	// Clone 按策略克隆仓库
+	Clone(repoURL, targetPath string, strategy GitStrategy) error
+	// Pull 更新当前分支
+	Pull(repoPath string) error
+	// Checkout 切换到分支、tag 或 commit，必要时按需获取
+	Checkout(repoPath, ref string, strategy GitStrategy) error
+	// Fetch 按策略获取远程更新
+	Fetch(repoPath string, strategy GitStrategy) error
+	// FetchPR 将 PR 的 head 获取到本地分支
+	FetchPR(repoPath string, prNumber int, localBranch string, strategy GitStrategy) error
+	// ResolveRef 在本地解析 ref（不访问网络），返回匹配的 ref 名称和 commit SHA
+	ResolveRef(repoPath, ref string) (string, string, error)
+	// IsShallow 判断仓库是否为浅克隆
+	IsShallow(repoPath string) bool
+	// Unshallow 获取完整历史
+	Unshallow(repoPath string) error
+	// UpdateSubmodules 递归初始化并更新子模块
+	UpdateSubmodules(repoPath string, strategy GitStrategy) error
+	// HasLFS 判断是否可以拉取 LFS 对象
+	HasLFS() bool
+	// LFSPull 拉取 LFS 对象，include 不为空时只拉取该路径
+	LFSPull(repoPath, include string) error
+	// LocalChanges 返回删除仓库会丢失的本地修改（未提交的修改或未推送的提交），没有时返回空字符串
+	LocalChanges(repoPath string) (string, error)
+	// WithLogger 返回使用 logger 记录日志的副本，用于带上请求 ID
+	WithLogger(logger *slog.Logger) GitClient
 }
 
-func NewGitClient(cacheDir string) *GitClient {
-	return &GitClient{cacheDir: cacheDir}
+// NewGitClient 根据配置的后端创建 GitClient
+func NewGitClient(backend, cacheDir, token string) (GitClient, error) {
+	switch backend {
+	case "", GitBackendExec:
+		return &ExecGitClient{cacheDir: cacheDir}, nil
+	case GitBackendGoGit:
+		return NewGoGitClient(cacheDir, token), nil
+	default:
+		return nil, fmt.Errorf("unsupported git backend: %s", backend)
+	}
 }
 
-// Clone 克隆仓库
-func (gc *GitClient) Clone(repoURL, targetPath string) error {
-	// 不使用 --single-branch，以便可以 checkout 其他分支
-	cmd := exec.Command("git", "clone",
-		"--filter=blob:none",
-		repoURL,
-		targetPath)
+// ExecGitClient 通过调用 git 命令实现 GitClient
+type ExecGitClient struct {
+	cacheDir string
+	sshHost  string       // 不为空时通过 ssh 在该主机上执行 git
+	log      *slog.Logger // 为空时使用默认 logger
+}
 
-	output, err := cmd.CombinedOutput()
+// Clone 按策略克隆仓库
+func (gc *ExecGitClient) Clone(repoURL, targetPath string, strategy GitStrategy) error {
+	args := []string{"clone"}
+	if strategy.Filter != "" && strategy.Filter != "none" {
+		args = append(args, "--filter="+strategy.Filter)
+	}
+	if strategy.Depth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", strategy.Depth))
+	}
+	// --depth 隐含 --single-branch，需要显式关闭以便可以 checkout 其他分支
+	if strategy.SingleBranch != nil {
+		if *strategy.SingleBranch {
+			args = append(args, "--single-branch")
+		} else {
+			args = append(args, "--no-single-branch")
+		}
+	}
+	if strategy.Tags != nil && !*strategy.Tags {
+		args = append(args, "--no-tags")
+	}
+	args = append(args, repoURL, targetPath)
+
+	cmd := gc.command("", args...)
+	output, err := gc.combinedOutput(cmd)
 	if err != nil {
-		return fmt.Errorf("git clone failed: %v\nOutput: %s", err, string(output))
+		return classifyGitError("git clone", err, output)
 	}
 	return nil
 }
 
 // Pull 更新仓库
-func (gc *GitClient) Pull(repoPath string) error {
-	cmd := exec.Command("git", "pull")
-	cmd.Dir = repoPath
+func (gc *ExecGitClient) Pull(repoPath string) error {
+	cmd := gc.command(repoPath, "pull")
 
-	output, err := cmd.CombinedOutput()
+	output, err := gc.combinedOutput(cmd)
 	if err != nil {
-		return fmt.Errorf("git pull failed: %v\nOutput: %s", err, string(output))
+		return classifyGitError("git pull", err, output)
 	}
 	return nil
 }
 
 // Checkout 切换分支或 tag
 // ref 可能包含路径（如 "feature/develop/src/file.go"），需要智能解析分支名
-func (gc *GitClient) Checkout(repoPath, ref string) error {
+// 如果 ref 在本地不可达（单分支、浅克隆或未获取 tag），会按需获取或加深历史后重试
+func (gc *ExecGitClient) Checkout(repoPath, ref string, strategy GitStrategy) error {
 	// 1. 先 fetch 确保远程引用是最新的
-	gc.Fetch(repoPath)
+	gc.Fetch(repoPath, strategy)
 
 	// 2. 尝试直接 checkout 整个 ref (适用于简单分支名或 tag)
-	if gc.tryCheckout(repoPath, ref) {
-		return nil
-	}
-
 	// 3. 如果失败，可能是因为 ref 包含路径，尝试从长到短匹配分支名
 	// 例如 "feature/develop/src/file" -> 尝试 "feature/develop/src/file", "feature/develop/src", "feature/develop", "feature"
+	err := gc.checkoutCandidates(repoPath, ref)
+	if err == nil || err.Code == ErrCodeDirtyTree {
+		return errorOrNil(err)
+	}
+
+	// 4. 按需获取：ref 可能不在已配置的 refspec 中，或是不可达的 commit
 	parts := strings.Split(ref, "/")
 	for i := len(parts); i >= 1; i-- {
 		candidate := strings.Join(parts[:i], "/")
-		if gc.tryCheckout(repoPath, candidate) {
+		if gc.fetchRef(repoPath, candidate, strategy) && gc.tryCheckout(repoPath, candidate) == nil {
 			return nil
 		}
 	}
 
-	return fmt.Errorf("git checkout failed for ref %s", ref)
+	// 5. 浅克隆时加深历史后重试
+	if gc.IsShallow(repoPath) {
+		if uerr := gc.Unshallow(repoPath); uerr == nil {
+			err = gc.checkoutCandidates(repoPath, ref)
+		}
+	}
+
+	return errorOrNil(err)
+}
+
+// checkoutCandidates 从长到短尝试 checkout ref 的各个前缀
+// 工作区有未提交修改时立即返回，不再尝试更短的前缀
+func (gc *ExecGitClient) checkoutCandidates(repoPath, ref string) *ServiceError {
+	var firstErr *ServiceError
+	parts := strings.Split(ref, "/")
+	for i := len(parts); i >= 1; i-- {
+		err := gc.tryCheckout(repoPath, strings.Join(parts[:i], "/"))
+		if err == nil || err.Code == ErrCodeDirtyTree {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	firstErr.Message = fmt.Sprintf("git checkout failed for ref %s", ref)
+	if firstErr.Code == ErrCodeGitFailed {
+		firstErr.Code = ErrCodeRefNotFound
+		firstErr.Hint = defaultHints[ErrCodeRefNotFound]
+	}
+	return firstErr
 }
 
-// tryCheckout 尝试 checkout 指定的 ref，成功返回 true
-func (gc *GitClient) tryCheckout(repoPath, ref string) bool {
+// tryCheckout 尝试 checkout 指定的 ref，失败时返回第一次尝试的分类错误
+func (gc *ExecGitClient) tryCheckout(repoPath, ref string) *ServiceError {
 	// 1. 尝试直接 checkout (本地分支或 tag)
-	cmd := exec.Command("git", "checkout", ref)
-	cmd.Dir = repoPath
-	if _, err := cmd.CombinedOutput(); err == nil {
-		return true
+	cmd := gc.command(repoPath, "checkout", ref)
+	output, err := gc.combinedOutput(cmd)
+	if err == nil {
+		return nil
 	}
+	firstErr := classifyGitError("git checkout", err, output)
 
 	// 2. 尝试从远程分支创建本地分支
-	cmd = exec.Command("git", "checkout", "-b", ref, fmt.Sprintf("origin/%s", ref))
-	cmd.Dir = repoPath
-	if _, err := cmd.CombinedOutput(); err == nil {
-		return true
+	cmd = gc.command(repoPath, "checkout", "-b", ref, fmt.Sprintf("origin/%s", ref))
+	if _, err := gc.combinedOutput(cmd); err == nil {
+		return nil
 	}
 
 	// 3. 尝试作为 tag 显式 checkout
-	cmd = exec.Command("git", "checkout", fmt.Sprintf("tags/%s", ref))
-	cmd.Dir = repoPath
-	if _, err := cmd.CombinedOutput(); err == nil {
-		return true
+	cmd = gc.command(repoPath, "checkout", fmt.Sprintf("tags/%s", ref))
+	if _, err := gc.combinedOutput(cmd); err == nil {
+		return nil
+	}
+
+	return firstErr
+}
+
+// ResolveRef 按 checkout 相同的顺序在本地解析 ref，不执行 fetch
+func (gc *ExecGitClient) ResolveRef(repoPath, ref string) (string, string, error) {
+	parts := strings.Split(ref, "/")
+	for i := len(parts); i >= 1; i-- {
+		candidate := strings.Join(parts[:i], "/")
+		for _, rev := range []string{candidate, "origin/" + candidate, "tags/" + candidate} {
+			cmd := gc.command(repoPath, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+			if output, err := gc.output(cmd); err == nil {
+				return candidate, strings.TrimSpace(string(output)), nil
+			}
+		}
+	}
+	return "", "", newServiceError(ErrCodeRefNotFound, fmt.Sprintf("ref %s not found locally", ref), nil)
+}
+
+// fetchRef 单独获取一个分支、tag 或 commit，成功返回 true
+func (gc *ExecGitClient) fetchRef(repoPath, ref string, strategy GitStrategy) bool {
+	refspecs := []string{
+		fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", ref, ref),
+		fmt.Sprintf("+refs/tags/%s:refs/tags/%s", ref, ref),
+	}
+	// GitHub 只允许按完整 SHA 获取 commit，缩写的 SHA 在加深历史后于本地解析
+	if fullSHAPattern.MatchString(ref) {
+		refspecs = append(refspecs, ref)
 	}
 
+	for _, refspec := range refspecs {
+		args := []string{"fetch", "--no-tags"}
+		args = append(args, gc.depthArgs(repoPath, strategy)...)
+		args = append(args, "origin", refspec)
+
+		cmd := gc.command(repoPath, args...)
+		if _, err := gc.combinedOutput(cmd); err == nil {
+			return true
+		}
+	}
 	return false
 }
 
-// Fetch 获取所有远程更新
-func (gc *GitClient) Fetch(repoPath string) error {
-	cmd := exec.Command("git", "fetch", "--all", "--tags")
-	cmd.Dir = repoPath
+// Fetch 按策略获取远程更新
+func (gc *ExecGitClient) Fetch(repoPath string, strategy GitStrategy) error {
+	args := []string{"fetch"}
+	if strategy.Tags != nil && !*strategy.Tags {
+		args = append(args, "--no-tags")
+	} else {
+		args = append(args, "--tags")
+	}
+	args = append(args, gc.depthArgs(repoPath, strategy)...)
+	if len(strategy.Refspecs) > 0 {
+		args = append(args, "origin")
+		args = append(args, strategy.Refspecs...)
+	} else {
+		args = append(args, "--all")
+	}
 
-	output, err := cmd.CombinedOutput()
+	cmd := gc.command(repoPath, args...)
+
+	output, err := gc.combinedOutput(cmd)
 	if err != nil {
-		return fmt.Errorf("git fetch failed: %v\nOutput: %s", err, string(output))
+		return classifyGitError("git fetch", err, output)
 	}
 	return nil
 }
 
 // FetchPR 获取 PR 分支（使用 GitHub 的 refs/pull/<number>/head 格式）
-func (gc *GitClient) FetchPR(repoPath string, prNumber int, localBranch string) error {
+// 浅克隆仓库中如果获取失败，会先加深历史再重试
+func (gc *ExecGitClient) FetchPR(repoPath string, prNumber int, localBranch string, strategy GitStrategy) error {
 	// 使用 git fetch origin pull/<PR_NUMBER>/head:<local_branch>
 	refspec := fmt.Sprintf("pull/%d/head:%s", prNumber, localBranch)
-	cmd := exec.Command("git", "fetch", "origin", refspec)
-	cmd.Dir = repoPath
+	args := []string{"fetch"}
+	args = append(args, gc.depthArgs(repoPath, strategy)...)
+	args = append(args, "origin", refspec)
+
+	cmd := gc.command(repoPath, args...)
+
+	output, err := gc.combinedOutput(cmd)
+	if err == nil {
+		return nil
+	}
+
+	if gc.IsShallow(repoPath) {
+		if uerr := gc.Unshallow(repoPath); uerr == nil {
+			cmd = gc.command(repoPath, "fetch", "origin", refspec)
+			if output, err = gc.combinedOutput(cmd); err == nil {
+				return nil
+			}
+		}
+	}
+	return classifyGitError("git fetch PR", err, output)
+}
+
+// gitCommand 创建在 dir 中执行的 git 命令
+// 禁用终端交互，避免需要认证的仓库让请求一直挂起；服务停止时命令会被取消
+// clone/checkout 不经过 LFS smudge 过滤器下载对象，LFS 文件由 prepareWorkingTree 按 lfs 策略拉取
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(jobsCtx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if len(args) == 0 || args[0] != "lfs" {
+		cmd.Env = append(cmd.Env, "GIT_LFS_SKIP_SMUDGE=1")
+	}
+	setTerminateOnCancel(cmd)
+	return cmd
+}
 
+// command 创建 git 命令，配置了 sshHost 时在远程主机上执行
+func (gc *ExecGitClient) command(dir string, args ...string) *exec.Cmd {
+	countGitCommand(args)
+	if gc.sshHost != "" {
+		return sshGitCommand(gc.sshHost, dir, args...)
+	}
+	return gitCommand(dir, args...)
+}
+
+// WithLogger 返回使用 logger 记录 git 命令的副本
+func (gc *ExecGitClient) WithLogger(logger *slog.Logger) GitClient {
+	scoped := *gc
+	scoped.log = logger
+	return &scoped
+}
+
+func (gc *ExecGitClient) logger() *slog.Logger {
+	if gc.log == nil {
+		return slog.Default()
+	}
+	return gc.log
+}
+
+// combinedOutput 执行 git 命令并返回合并的 stdout 和 stderr
+func (gc *ExecGitClient) combinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
+	gc.logCommand(cmd, start, err)
+	return output, err
+}
+
+// output 执行 git 命令并返回 stdout
+func (gc *ExecGitClient) output(cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	output, err := cmd.Output()
+	gc.logCommand(cmd, start, err)
+	return output, err
+}
+
+// logCommand 在 debug 级别记录 git 命令行、目录和耗时，命令行中的凭据会被去除
+func (gc *ExecGitClient) logCommand(cmd *exec.Cmd, start time.Time, err error) {
+	attrs := []any{
+		"command", sanitizeOutput(strings.Join(cmd.Args, " ")),
+		"dir", cmd.Dir,
+		"duration_ms", time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+	gc.logger().Debug("git command", attrs...)
+}
+
+// errorOrNil 避免将 nil 的 *ServiceError 作为非 nil 的 error 返回
+func errorOrNil(err *ServiceError) error {
+	if err == nil {
+		return nil
+	}
+	return err
+}
+
+// depthArgs 返回 fetch 使用的 --depth 参数
+// 仅对仍是浅克隆的仓库生效，避免把已加深的完整历史重新截断；策略要求完整历史时加深浅克隆
+func (gc *ExecGitClient) depthArgs(repoPath string, strategy GitStrategy) []string {
+	if strategy.Depth == 0 || !gc.IsShallow(repoPath) {
+		return nil
+	}
+	if strategy.Depth == DepthFull {
+		return []string{"--unshallow"}
+	}
+	return []string{fmt.Sprintf("--depth=%d", strategy.Depth)}
+}
+
+// IsShallow 判断仓库是否为浅克隆
+func (gc *ExecGitClient) IsShallow(repoPath string) bool {
+	cmd := gc.command(repoPath, "rev-parse", "--is-shallow-repository")
+	output, err := gc.output(cmd)
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// Unshallow 获取完整历史，用于请求的 commit 或 PR 在浅克隆中不可达时
+func (gc *ExecGitClient) Unshallow(repoPath string) error {
+	cmd := gc.command(repoPath, "fetch", "--unshallow", "origin")
+
+	output, err := gc.combinedOutput(cmd)
+	if err != nil {
+		return classifyGitError("git fetch --unshallow", err, output)
+	}
+	return nil
+}
+
+// hasSubmodules 判断仓库是否包含子模块
+func hasSubmodules(repoPath string) bool {
+	_, err := os.Stat(filepath.Join(repoPath, ".gitmodules"))
+	return err == nil
+}
+
+// UpdateSubmodules 递归初始化并更新子模块，沿用仓库的 filter/depth 策略
+func (gc *ExecGitClient) UpdateSubmodules(repoPath string, strategy GitStrategy) error {
+	args := []string{"submodule", "update", "--init", "--recursive"}
+	if strategy.Filter != "" && strategy.Filter != "none" {
+		args = append(args, "--filter="+strategy.Filter)
+	}
+	if strategy.Depth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", strategy.Depth))
+	}
+
+	cmd := gc.command(repoPath, args...)
+
+	output, err := gc.combinedOutput(cmd)
+	if err != nil {
+		return classifyGitError("git submodule update", err, output)
+	}
+	return nil
+}
+
+// usesLFS 判断仓库的 .gitattributes 是否配置了 LFS 过滤器
+func usesLFS(repoPath string) bool {
+	data, err := os.ReadFile(filepath.Join(repoPath, ".gitattributes"))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), "filter=lfs")
+}
+
+// HasLFS 判断 git-lfs 是否已安装
+func (gc *ExecGitClient) HasLFS() bool {
+	_, err := gc.output(gc.command("", "lfs", "version"))
+	return err == nil
+}
+
+// LocalChanges 检查未提交的修改（包括未跟踪的文件）和远程分支、tag 都不包含的提交
+// 打开 PR 时创建的 pr-<number> 分支来自远程，不算作本地提交
+func (gc *ExecGitClient) LocalChanges(repoPath string) (string, error) {
+	output, err := gc.output(gc.command(repoPath, "status", "--porcelain"))
+	if err != nil {
+		return "", classifyGitError("git status", err, output)
+	}
+	if len(strings.TrimSpace(string(output))) > 0 {
+		return "uncommitted changes", nil
+	}
+
+	cmd := gc.command(repoPath, "rev-list", "--count", "HEAD", "--branches", "--not", "--remotes", "--tags", "--glob=refs/heads/pr-*")
+	output, err = gc.output(cmd)
+	if err != nil {
+		return "", classifyGitError("git rev-list", err, output)
+	}
+	if count := strings.TrimSpace(string(output)); count != "0" {
+		return fmt.Sprintf("%s unpushed commits", count), nil
+	}
+	return "", nil
+}
+
+// LFSPull 拉取 LFS 对象，include 不为空时只拉取该路径（相对仓库根目录）
+func (gc *ExecGitClient) LFSPull(repoPath, include string) error {
+	args := []string{"lfs", "pull"}
+	if include != "" {
+		args = append(args, "--include", include)
+	}
+
+	cmd := gc.command(repoPath, args...)
+
+	output, err := gc.combinedOutput(cmd)
 	if err != nil {
-		return fmt.Errorf("git fetch PR failed: %v\nOutput: %s", err, string(output))
+		return classifyGitError("git lfs pull", err, output)
 	}
 	return nil
 }
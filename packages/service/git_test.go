@@ -0,0 +1,300 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitFixture 是测试中创建的远程仓库：src 为工作仓库，remote 为克隆使用的裸仓库
+type gitFixture struct {
+	t      *testing.T
+	src    string
+	remote string
+	shas   map[string]string // 提交说明到 SHA 的映射
+}
+
+// newGitFixture 创建包含 main、feature/x 分支、v1.0 tag 和 PR #7 的裸仓库
+//
+//	main:      c1 (v1.0) - c2 - c3
+//	feature/x: c1 - feature
+//	pull/7:    c3 - pr
+func newGitFixture(t *testing.T) *gitFixture {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	f := &gitFixture{t: t, src: filepath.Join(dir, "src"), remote: filepath.Join(dir, "remote.git"), shas: map[string]string{}}
+
+	f.git(dir, "init", "--quiet", "--initial-branch=main", f.src)
+	f.commit("c1")
+	f.git(f.src, "tag", "-a", "v1.0", "-m", "v1.0")
+	f.git(f.src, "checkout", "--quiet", "-b", "feature/x")
+	f.commit("feature")
+	f.git(f.src, "checkout", "--quiet", "main")
+	f.commit("c2")
+	f.commit("c3")
+	f.git(f.src, "checkout", "--quiet", "-b", "pr")
+	f.commit("pr")
+	f.git(f.src, "checkout", "--quiet", "main")
+
+	f.git(dir, "clone", "--quiet", "--bare", f.src, f.remote)
+	f.git(f.remote, "update-ref", "refs/pull/7/head", f.shas["pr"])
+	f.git(f.remote, "branch", "--quiet", "-D", "pr")
+	return f
+}
+
+// url 返回裸仓库的 file:// URL
+// 直接使用本地路径时 git 会忽略 --depth，file:// URL 会按 --depth 浅克隆，TestGitClientShallow 依赖这一点
+func (f *gitFixture) url() string {
+	return "file://" + filepath.ToSlash(f.remote)
+}
+
+func (f *gitFixture) git(dir string, args ...string) string {
+	f.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		f.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// commit 在 src 的当前分支上提交，file 的内容为提交说明
+func (f *gitFixture) commit(message string) {
+	f.t.Helper()
+	if err := os.WriteFile(filepath.Join(f.src, "file"), []byte(message), 0644); err != nil {
+		f.t.Fatal(err)
+	}
+	f.git(f.src, "add", "file")
+	f.git(f.src, "commit", "--quiet", "-m", message)
+	f.shas[message] = f.git(f.src, "rev-parse", "HEAD")
+}
+
+// push 在 main 上提交并推送到裸仓库
+func (f *gitFixture) push(message string) {
+	f.t.Helper()
+	f.commit(message)
+	f.git(f.src, "push", "--quiet", f.remote, "main")
+}
+
+// gitBackends 返回要进行一致性测试的 GitClient 实现
+func gitBackends() map[string]GitClient {
+	return map[string]GitClient{
+		GitBackendExec:  &ExecGitClient{},
+		GitBackendGoGit: NewGoGitClient("", ""),
+	}
+}
+
+func assertFile(t *testing.T, repoPath, want string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(repoPath, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+}
+
+func TestGitClientClone(t *testing.T) {
+	f := newGitFixture(t)
+	for name, gc := range gitBackends() {
+		t.Run(name, func(t *testing.T) {
+			repoPath := filepath.Join(t.TempDir(), "repo")
+			if err := gc.Clone(f.url(), repoPath, GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			assertFile(t, repoPath, "c3")
+			if gc.IsShallow(repoPath) {
+				t.Error("full clone is shallow")
+			}
+			if _, sha, err := gc.ResolveRef(repoPath, "main"); err != nil || sha != f.shas["c3"] {
+				t.Errorf("ResolveRef(main) = %s, %v, want %s", sha, err, f.shas["c3"])
+			}
+		})
+	}
+}
+
+func TestGitClientCheckout(t *testing.T) {
+	f := newGitFixture(t)
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{"branch", "feature/x", "feature"},
+		{"branch with path", "feature/x/src/main.go", "feature"},
+		{"tag", "v1.0", "c1"},
+		{"full sha", f.shas["c2"], "c2"},
+		{"main", "main", "c3"},
+	}
+	for name, gc := range gitBackends() {
+		t.Run(name, func(t *testing.T) {
+			repoPath := filepath.Join(t.TempDir(), "repo")
+			if err := gc.Clone(f.url(), repoPath, GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			for _, tt := range tests {
+				if err := gc.Checkout(repoPath, tt.ref, GitStrategy{}); err != nil {
+					t.Errorf("%s: Checkout(%s): %v", tt.name, tt.ref, err)
+					continue
+				}
+				assertFile(t, repoPath, tt.want)
+			}
+
+			err := gc.Checkout(repoPath, "no-such-branch", GitStrategy{})
+			if se := asServiceError(err); err == nil || se.Code != ErrCodeRefNotFound {
+				t.Errorf("Checkout(no-such-branch) = %v, want %s", err, ErrCodeRefNotFound)
+			}
+		})
+	}
+}
+
+func TestGitClientFetch(t *testing.T) {
+	f := newGitFixture(t)
+	for name, gc := range gitBackends() {
+		t.Run(name, func(t *testing.T) {
+			repoPath := filepath.Join(t.TempDir(), "repo")
+			if err := gc.Clone(f.url(), repoPath, GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			f.push("c4-" + name)
+			if err := gc.Fetch(repoPath, GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, sha, err := gc.ResolveRef(repoPath, "origin/main"); err != nil || sha != f.shas["c4-"+name] {
+				t.Errorf("ResolveRef(origin/main) = %s, %v, want %s", sha, err, f.shas["c4-"+name])
+			}
+		})
+	}
+}
+
+func TestGitClientFetchPR(t *testing.T) {
+	f := newGitFixture(t)
+	for name, gc := range gitBackends() {
+		t.Run(name, func(t *testing.T) {
+			repoPath := filepath.Join(t.TempDir(), "repo")
+			if err := gc.Clone(f.url(), repoPath, GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := gc.FetchPR(repoPath, 7, "pr-7", GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := gc.Checkout(repoPath, "pr-7", GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			assertFile(t, repoPath, "pr")
+
+			if err := gc.FetchPR(repoPath, 8, "pr-8", GitStrategy{}); err == nil {
+				t.Error("FetchPR(8) succeeded for a missing pull request")
+			}
+		})
+	}
+}
+
+func TestGitClientShallow(t *testing.T) {
+	f := newGitFixture(t)
+	shallow := GitStrategy{Depth: 1}
+	for name, gc := range gitBackends() {
+		t.Run(name, func(t *testing.T) {
+			repoPath := filepath.Join(t.TempDir(), "repo")
+			if err := gc.Clone(f.url(), repoPath, shallow); err != nil {
+				t.Fatal(err)
+			}
+			if !gc.IsShallow(repoPath) {
+				t.Fatal("clone with depth 1 is not shallow")
+			}
+
+			// 浅克隆中不可达的 commit 需要按需获取或加深历史
+			if err := gc.Checkout(repoPath, f.shas["c1"], shallow); err != nil {
+				t.Fatalf("Checkout(c1): %v", err)
+			}
+			assertFile(t, repoPath, "c1")
+
+			if err := gc.Unshallow(repoPath); err != nil {
+				t.Fatal(err)
+			}
+			if gc.IsShallow(repoPath) {
+				t.Error("repository is still shallow after Unshallow")
+			}
+			if err := gc.Checkout(repoPath, f.shas["c2"][:10], shallow); err != nil {
+				t.Errorf("Checkout(short sha): %v", err)
+			}
+			assertFile(t, repoPath, "c2")
+
+			// 缩写的 SHA 不能直接获取，需要先加深历史
+			repoPath = filepath.Join(t.TempDir(), "repo")
+			if err := gc.Clone(f.url(), repoPath, shallow); err != nil {
+				t.Fatal(err)
+			}
+			if err := gc.Checkout(repoPath, f.shas["c2"][:10], shallow); err != nil {
+				t.Errorf("Checkout(short sha) in shallow clone: %v", err)
+			}
+			assertFile(t, repoPath, "c2")
+
+			// depth 为 -1 时 fetch 加深已有的浅克隆
+			repoPath = filepath.Join(t.TempDir(), "repo")
+			if err := gc.Clone(f.url(), repoPath, shallow); err != nil {
+				t.Fatal(err)
+			}
+			if err := gc.Fetch(repoPath, GitStrategy{Depth: DepthFull}); err != nil {
+				t.Fatal(err)
+			}
+			if gc.IsShallow(repoPath) {
+				t.Error("repository is still shallow after fetch with depth -1")
+			}
+		})
+	}
+}
+
+func TestGitClientLocalChanges(t *testing.T) {
+	f := newGitFixture(t)
+	for name, gc := range gitBackends() {
+		t.Run(name, func(t *testing.T) {
+			repoPath := filepath.Join(t.TempDir(), "repo")
+			if err := gc.Clone(f.url(), repoPath, GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			assertLocalChanges := func(step string, changed bool) {
+				t.Helper()
+				reason, err := gc.LocalChanges(repoPath)
+				if err != nil {
+					t.Fatalf("%s: %v", step, err)
+				}
+				if (reason != "") != changed {
+					t.Errorf("%s: LocalChanges = %q, want changes: %v", step, reason, changed)
+				}
+			}
+
+			assertLocalChanges("fresh clone", false)
+
+			// PR 分支和 tag 上的提交来自远程
+			if err := gc.FetchPR(repoPath, 7, "pr-7", GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := gc.Checkout(repoPath, "v1.0", GitStrategy{}); err != nil {
+				t.Fatal(err)
+			}
+			assertLocalChanges("pr branch and tag", false)
+
+			if err := os.WriteFile(filepath.Join(repoPath, "untracked"), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			assertLocalChanges("untracked file", true)
+			f.git(repoPath, "checkout", "--quiet", "-b", "work")
+			f.git(repoPath, "add", "untracked")
+			f.git(repoPath, "commit", "--quiet", "-m", "local")
+			assertLocalChanges("unpushed commit", true)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -18,13 +19,13 @@ const (
 )
 
 type GitHubURLInfo struct {
-	Owner    string
-	Repo     string
-	Type     URLType
-	Branch   string
-	FilePath string
-	Line     int
-	PRNumber int
+	Owner    string  `json:"owner"`
+	Repo     string  `json:"repo"`
+	Type     URLType `json:"type"`
+	Branch   string  `json:"branch,omitempty"`
+	FilePath string  `json:"filePath,omitempty"`
+	Line     int     `json:"line,omitempty"`
+	PRNumber int     `json:"prNumber,omitempty"`
 }
 
 type PullRequestInfo struct {
@@ -54,7 +55,7 @@ func (gc *GitHubClient) GetPullRequest(owner, repo string, number int) (*PullReq
 	ctx := context.Background()
 	pr, _, err := gc.client.PullRequests.Get(ctx, owner, repo, number)
 	if err != nil {
-		return nil, err
+		return nil, classifyGitHubError(fmt.Sprintf("get pull request #%d", number), err, ErrCodePRNotFound)
 	}
 
 	return &PullRequestInfo{
@@ -67,6 +68,51 @@ func (gc *GitHubClient) GetPullRequest(owner, repo string, number int) (*PullReq
 	}, nil
 }
 
+// GetPrimaryLanguage 通过 languages API 返回仓库中代码量最大的语言
+func (gc *GitHubClient) GetPrimaryLanguage(ctx context.Context, owner, repo string) (string, error) {
+	languages, _, err := gc.client.Repositories.ListLanguages(ctx, owner, repo)
+	if err != nil {
+		return "", classifyGitHubError("list languages", err, ErrCodeRepoNotFound)
+	}
+
+	var primary string
+	var maxBytes int
+	for lang, bytes := range languages {
+		if bytes > maxBytes || (bytes == maxBytes && lang < primary) {
+			primary, maxBytes = lang, bytes
+		}
+	}
+	return primary, nil
+}
+
+// classifyGitHubError 根据 go-github 返回的错误分类，notFound 是该接口返回 404 时的错误码
+// 连接失败、超时和 GitHub 的 5xx 错误都视为网络错误，可以稍后重试
+func classifyGitHubError(op string, err error, notFound ErrorCode) *ServiceError {
+	code := ErrCodeNetwork
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var respErr *github.ErrorResponse
+	switch {
+	case errors.As(err, &rateErr), errors.As(err, &abuseErr):
+		code = ErrCodeRateLimited
+	case errors.As(err, &respErr):
+		switch status := respErr.Response.StatusCode; {
+		case status == 401 || status == 403:
+			code = ErrCodeAuthRequired
+		case status == 404:
+			code = notFound
+		case status >= 500:
+			code = ErrCodeNetwork
+		default:
+			code = ErrCodeInternal
+		}
+	}
+
+	se := newServiceError(code, fmt.Sprintf("%s failed: %s", op, strings.ReplaceAll(string(code), "_", " ")), err)
+	se.Details = sanitizeOutput(err.Error())
+	return se
+}
+
 // ParseGitHubURL 解析各种 GitHub URL 格式
 func ParseGitHubURL(url string) (*GitHubURLInfo, error) {
 	// 移除尾部斜杠
@@ -145,5 +191,5 @@ func ParseGitHubURL(url string) (*GitHubURLInfo, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("unsupported GitHub URL format: %s", url)
+	return nil, newServiceError(ErrCodeInvalidURL, fmt.Sprintf("unsupported GitHub URL format: %s", url), nil)
 }
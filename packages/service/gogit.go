@@ -0,0 +1,480 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// GoGitClient 基于 go-git 实现 GitClient，不依赖系统安装的 git
+// 限制：不支持 partial clone 过滤器（总是获取完整对象）以及 LFS
+type GoGitClient struct {
+	cacheDir string
+	auth     transport.AuthMethod
+	log      *slog.Logger // 为空时使用默认 logger
+}
+
+func NewGoGitClient(cacheDir, token string) *GoGitClient {
+	gc := &GoGitClient{cacheDir: cacheDir}
+	if token != "" {
+		gc.auth = &http.BasicAuth{Username: "x-access-token", Password: token}
+	}
+	return gc
+}
+
+// Clone 按策略克隆仓库，filter 会被忽略
+func (gc *GoGitClient) Clone(repoURL, targetPath string, strategy GitStrategy) error {
+	opts := &git.CloneOptions{
+		URL:   repoURL,
+		Auth:  gc.auth,
+		Depth: strategy.cloneDepth(),
+		Tags:  tagMode(strategy),
+	}
+	if strategy.SingleBranch != nil {
+		opts.SingleBranch = *strategy.SingleBranch
+	}
+
+	start := time.Now()
+	_, err := git.PlainCloneContext(jobsCtx, targetPath, false, opts)
+	gc.logOperation("clone", targetPath, start, err)
+	if err != nil {
+		return classifyGoGitError("git clone", err)
+	}
+	return nil
+}
+
+// Pull 更新仓库
+func (gc *GoGitClient) Pull(repoPath string) error {
+	wt, err := gc.worktree(repoPath)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = wt.PullContext(jobsCtx, &git.PullOptions{RemoteName: "origin", Auth: gc.auth})
+	gc.logOperation("pull", repoPath, start, err)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return classifyGoGitError("git pull", err)
+	}
+	return nil
+}
+
+// Checkout 切换分支、tag 或 commit
+// 与 ExecGitClient 一样从长到短尝试 ref 的前缀，本地不存在时单独获取或加深历史后重试
+func (gc *GoGitClient) Checkout(repoPath, ref string, strategy GitStrategy) error {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	gc.Fetch(repoPath, strategy)
+
+	var firstErr error
+	parts := strings.Split(ref, "/")
+	for i := len(parts); i >= 1; i-- {
+		err := gc.tryCheckout(repo, strings.Join(parts[:i], "/"))
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, git.ErrUnstagedChanges) {
+			return newServiceError(ErrCodeDirtyTree, fmt.Sprintf("git checkout failed for ref %s: dirty tree", ref), err)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for i := len(parts); i >= 1; i-- {
+		candidate := strings.Join(parts[:i], "/")
+		if gc.fetchRef(repo, candidate, strategy) && gc.tryCheckout(repo, candidate) == nil {
+			return nil
+		}
+	}
+
+	// 浅克隆时加深历史后重试，如缩写的 SHA
+	if gc.IsShallow(repoPath) && gc.Unshallow(repoPath) == nil {
+		// 重新打开仓库以读取新获取的对象
+		if repo, err = git.PlainOpen(repoPath); err != nil {
+			return fmt.Errorf("failed to open repository: %w", err)
+		}
+		for i := len(parts); i >= 1; i-- {
+			if gc.tryCheckout(repo, strings.Join(parts[:i], "/")) == nil {
+				return nil
+			}
+		}
+	}
+
+	se := newServiceError(ErrCodeRefNotFound, fmt.Sprintf("git checkout failed for ref %s", ref), firstErr)
+	se.Details = sanitizeOutput(firstErr.Error())
+	return se
+}
+
+// tryCheckout 依次尝试本地分支、远程分支、tag 和 commit
+func (gc *GoGitClient) tryCheckout(repo *git.Repository, ref string) error {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	// 1. 本地分支
+	branch := plumbing.NewBranchReferenceName(ref)
+	if _, err := repo.Reference(branch, false); err == nil {
+		return wt.Checkout(&git.CheckoutOptions{Branch: branch})
+	}
+
+	// 2. 从远程分支创建本地分支
+	if remote, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", ref), true); err == nil {
+		return wt.Checkout(&git.CheckoutOptions{Branch: branch, Hash: remote.Hash(), Create: true})
+	}
+
+	// 3. tag 或 commit，以 detached HEAD 方式 checkout
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return err
+	}
+	if tag, err := repo.TagObject(*hash); err == nil {
+		commit, err := tag.Commit()
+		if err != nil {
+			return err
+		}
+		return wt.Checkout(&git.CheckoutOptions{Hash: commit.Hash})
+	}
+	return wt.Checkout(&git.CheckoutOptions{Hash: *hash})
+}
+
+// ResolveRef 按 checkout 相同的顺序在本地解析 ref，不执行 fetch
+func (gc *GoGitClient) ResolveRef(repoPath, ref string) (string, string, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	parts := strings.Split(ref, "/")
+	for i := len(parts); i >= 1; i-- {
+		candidate := strings.Join(parts[:i], "/")
+		for _, rev := range []string{candidate, "refs/remotes/origin/" + candidate} {
+			if hash, err := repo.ResolveRevision(plumbing.Revision(rev + "^{commit}")); err == nil {
+				return candidate, hash.String(), nil
+			}
+		}
+	}
+	return "", "", newServiceError(ErrCodeRefNotFound, fmt.Sprintf("ref %s not found locally", ref), nil)
+}
+
+// fetchRef 单独获取一个分支或 tag，成功返回 true
+func (gc *GoGitClient) fetchRef(repo *git.Repository, ref string, strategy GitStrategy) bool {
+	refspecs := []gitconfig.RefSpec{
+		gitconfig.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", ref, ref)),
+		gitconfig.RefSpec(fmt.Sprintf("+refs/tags/%s:refs/tags/%s", ref, ref)),
+	}
+	for _, refspec := range refspecs {
+		err := repo.FetchContext(jobsCtx, &git.FetchOptions{
+			RemoteName: "origin",
+			RefSpecs:   []gitconfig.RefSpec{refspec},
+			Auth:       gc.auth,
+			Depth:      strategy.cloneDepth(),
+			Tags:       git.NoTags,
+		})
+		if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch 按策略获取远程更新
+func (gc *GoGitClient) Fetch(repoPath string, strategy GitStrategy) error {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	var refspecs []gitconfig.RefSpec
+	for _, r := range strategy.Refspecs {
+		refspecs = append(refspecs, gitconfig.RefSpec(r))
+	}
+
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return classifyGoGitError("git fetch", err)
+	}
+	for _, remote := range remotes {
+		name := remote.Config().Name
+		// 与 `git fetch origin <refspec>` 一致，自定义 refspec 只用于 origin
+		if len(refspecs) > 0 && name != "origin" {
+			continue
+		}
+		start := time.Now()
+		err := repo.FetchContext(jobsCtx, &git.FetchOptions{
+			RemoteName: name,
+			RefSpecs:   refspecs,
+			Auth:       gc.auth,
+			Depth:      gc.depth(repo, strategy),
+			Tags:       tagMode(strategy),
+		})
+		gc.logOperation("fetch "+name, repoPath, start, err)
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return classifyGoGitError("git fetch "+name, err)
+		}
+	}
+	if strategy.Depth == DepthFull && gc.IsShallow(repoPath) {
+		return gc.Unshallow(repoPath)
+	}
+	return nil
+}
+
+// FetchPR 获取 PR 分支（使用 GitHub 的 refs/pull/<number>/head 格式）
+func (gc *GoGitClient) FetchPR(repoPath string, prNumber int, localBranch string, strategy GitStrategy) error {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	refspec := gitconfig.RefSpec(fmt.Sprintf("+refs/pull/%d/head:refs/heads/%s", prNumber, localBranch))
+	start := time.Now()
+	err = repo.FetchContext(jobsCtx, &git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []gitconfig.RefSpec{refspec},
+		Auth:       gc.auth,
+		Depth:      gc.depth(repo, strategy),
+		Tags:       git.NoTags,
+	})
+	gc.logOperation("fetch origin "+string(refspec), repoPath, start, err)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return classifyGoGitError("git fetch PR", err)
+	}
+	return nil
+}
+
+// depth 返回 fetch 使用的深度，仅对浅克隆生效
+func (gc *GoGitClient) depth(repo *git.Repository, strategy GitStrategy) int {
+	if shallow, err := repo.Storer.Shallow(); err == nil && len(shallow) > 0 {
+		return strategy.cloneDepth()
+	}
+	return 0
+}
+
+// IsShallow 判断仓库是否为浅克隆
+func (gc *GoGitClient) IsShallow(repoPath string) bool {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return false
+	}
+	shallow, err := repo.Storer.Shallow()
+	return err == nil && len(shallow) > 0
+}
+
+// Unshallow 获取完整历史，与 git fetch --unshallow 一样以最大深度获取 origin
+func (gc *GoGitClient) Unshallow(repoPath string) error {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	start := time.Now()
+	err = repo.FetchContext(jobsCtx, &git.FetchOptions{
+		RemoteName: "origin",
+		Auth:       gc.auth,
+		Depth:      math.MaxInt32,
+		Tags:       git.NoTags,
+	})
+	gc.logOperation("fetch --unshallow origin", repoPath, start, err)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return classifyGoGitError("git fetch --unshallow", err)
+	}
+
+	// go-git 获取后不会更新 shallow 列表，父提交都已获取的提交不再是浅克隆的边界
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return err
+	}
+	var remaining []plumbing.Hash
+	for _, hash := range shallow {
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			remaining = append(remaining, hash)
+			continue
+		}
+		for _, parent := range commit.ParentHashes {
+			if _, err := repo.CommitObject(parent); err != nil {
+				remaining = append(remaining, hash)
+				break
+			}
+		}
+	}
+	return repo.Storer.SetShallow(remaining)
+}
+
+// UpdateSubmodules 递归初始化并更新子模块
+func (gc *GoGitClient) UpdateSubmodules(repoPath string, strategy GitStrategy) error {
+	wt, err := gc.worktree(repoPath)
+	if err != nil {
+		return err
+	}
+
+	submodules, err := wt.Submodules()
+	if err != nil {
+		return classifyGoGitError("git submodule update", err)
+	}
+	err = submodules.UpdateContext(jobsCtx, &git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		Auth:              gc.auth,
+		Depth:             strategy.cloneDepth(),
+	})
+	if err != nil {
+		return classifyGoGitError("git submodule update", err)
+	}
+	return nil
+}
+
+// HasLFS go-git 不支持 LFS
+func (gc *GoGitClient) HasLFS() bool {
+	return false
+}
+
+// LocalChanges 检查未提交的修改（包括未跟踪的文件）和远程分支、tag 都不包含的提交
+// 打开 PR 时创建的 pr-<number> 分支来自远程，不算作本地提交
+func (gc *GoGitClient) LocalChanges(repoPath string) (string, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return "", classifyGoGitError("git status", err)
+	}
+	if !status.IsClean() {
+		return "uncommitted changes", nil
+	}
+
+	// 本地分支和 HEAD 指向的提交需要能从远程分支、tag 或 PR 分支到达
+	local := map[plumbing.Hash]bool{}
+	var remote []plumbing.Hash
+	refs, err := repo.References()
+	if err != nil {
+		return "", err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		hash, err := repo.ResolveRevision(plumbing.Revision(name.String() + "^{commit}"))
+		if err != nil {
+			return nil
+		}
+		switch {
+		case name.IsRemote(), name.IsTag(), name.IsBranch() && strings.HasPrefix(name.Short(), "pr-"):
+			remote = append(remote, *hash)
+		case name.IsBranch(), name == plumbing.HEAD:
+			local[*hash] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 从远程的提交向前遍历历史，找到所有本地提交后停止；浅克隆中缺失的提交被跳过
+	seen := map[plumbing.Hash]bool{}
+	for len(remote) > 0 && len(local) > 0 {
+		hash := remote[len(remote)-1]
+		remote = remote[:len(remote)-1]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		delete(local, hash)
+		if commit, err := repo.CommitObject(hash); err == nil {
+			remote = append(remote, commit.ParentHashes...)
+		}
+	}
+	if len(local) > 0 {
+		return "unpushed commits", nil
+	}
+	return "", nil
+}
+
+// LFSPull go-git 不支持 LFS
+func (gc *GoGitClient) LFSPull(repoPath, include string) error {
+	return fmt.Errorf("git lfs is not supported by the %s backend", GitBackendGoGit)
+}
+
+func (gc *GoGitClient) worktree(repoPath string) (*git.Worktree, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	return repo.Worktree()
+}
+
+// tagMode 将策略中的 tags 开关转换为 go-git 的 TagMode
+func tagMode(strategy GitStrategy) git.TagMode {
+	if strategy.Tags != nil && !*strategy.Tags {
+		return git.NoTags
+	}
+	return git.AllTags
+}
+
+// classifyGoGitError 根据 go-git 返回的错误类型分类
+func classifyGoGitError(op string, err error) *ServiceError {
+	if jobsCtx.Err() != nil {
+		return newServiceError(ErrCodeServiceStopping, op+" canceled: the service is shutting down", err)
+	}
+	code := ErrCodeGitFailed
+	switch {
+	case errors.Is(err, transport.ErrRepositoryNotFound), errors.Is(err, git.ErrRepositoryNotExists):
+		code = ErrCodeRepoNotFound
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed):
+		code = ErrCodeAuthRequired
+	case errors.Is(err, plumbing.ErrReferenceNotFound), errors.Is(err, git.NoMatchingRefSpecError{}):
+		code = ErrCodeRefNotFound
+	case errors.Is(err, git.ErrUnstagedChanges):
+		code = ErrCodeDirtyTree
+	}
+
+	se := newServiceError(code, fmt.Sprintf("%s failed", op), err)
+	if code != ErrCodeGitFailed {
+		se.Message = fmt.Sprintf("%s failed: %s", op, strings.ReplaceAll(string(code), "_", " "))
+	}
+	se.Details = sanitizeOutput(err.Error())
+	return se
+}
+
+// WithLogger 返回使用 logger 记录 git 操作的副本
+func (gc *GoGitClient) WithLogger(logger *slog.Logger) GitClient {
+	scoped := *gc
+	scoped.log = logger
+	return &scoped
+}
+
+// logOperation 在 debug 级别记录访问网络的 go-git 操作及耗时
+func (gc *GoGitClient) logOperation(operation, repoPath string, start time.Time, err error) {
+	logger := gc.log
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := []any{
+		"operation", operation,
+		"dir", repoPath,
+		"duration_ms", time.Since(start).Milliseconds(),
+	}
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		attrs = append(attrs, "error", sanitizeOutput(err.Error()))
+	}
+	logger.Debug("go-git operation", attrs...)
+}
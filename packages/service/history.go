@@ -0,0 +1,582 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHistoryMaxEntries = 1000
+	defaultHistoryMaxAge     = 90 * 24 * time.Hour
+
+	defaultHistoryQueryLimit = 50
+	maxHistoryQueryLimit     = 500
+)
+
+// errorMessageKey 是 respondError 在 gin.Context 中记录错误信息的键，用于写入打开历史
+const errorMessageKey = "errorMessage"
+
+// HistoryConfig 配置打开历史的保留策略
+type HistoryConfig struct {
+	Disabled   bool   `json:"disabled,omitempty"`   // 不记录打开历史
+	MaxEntries int    `json:"maxEntries,omitempty"` // 最多保留的记录数，默认 1000
+	MaxAge     string `json:"maxAge,omitempty"`     // 记录的保留时间，如 "30d"、"720h"，默认 90 天
+}
+
+func (hc *HistoryConfig) enabled() bool {
+	return hc == nil || !hc.Disabled
+}
+
+func (hc *HistoryConfig) maxEntries() int {
+	if hc == nil || hc.MaxEntries == 0 {
+		return defaultHistoryMaxEntries
+	}
+	return hc.MaxEntries
+}
+
+func (hc *HistoryConfig) maxAge() time.Duration {
+	if hc != nil && hc.MaxAge != "" {
+		if d, err := parseAge(hc.MaxAge); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultHistoryMaxAge
+}
+
+// validate 检查保留策略是否有效
+func (hc *HistoryConfig) validate() error {
+	if hc.MaxEntries < 0 {
+		return fmt.Errorf("maxEntries: must not be negative")
+	}
+	if hc.MaxAge != "" {
+		if d, err := parseAge(hc.MaxAge); err != nil || d == 0 {
+			return fmt.Errorf("maxAge: invalid duration %q, use e.g. \"30d\" or \"720h\"", hc.MaxAge)
+		}
+	}
+	return nil
+}
+
+// HistoryEntry 是一次打开的记录
+type HistoryEntry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	URL        string    `json:"url"`
+	Repo       string    `json:"repo,omitempty"` // owner/repo，URL 无法解析时为空
+	Type       URLType   `json:"type,omitempty"`
+	Ref        string    `json:"ref,omitempty"`      // 分支、tag 或 commit
+	PRNumber   int       `json:"prNumber,omitempty"` // PR 编号
+	FilePath   string    `json:"filePath,omitempty"`
+	Line       int       `json:"line,omitempty"`
+	Column     int       `json:"column,omitempty"`
+	IDE        string    `json:"ide,omitempty"`    // 实际使用的 IDE
+	Target     string    `json:"target,omitempty"` // local、devcontainer 或 ssh
+	Path       string    `json:"path,omitempty"`   // 本地仓库路径
+	Status     string    `json:"status"`           // ok 或 error
+	Code       ErrorCode `json:"code,omitempty"`   // 失败时的错误码
+	Message    string    `json:"message,omitempty"`
+	Batch      bool      `json:"batch,omitempty"` // 通过 /open/batch 打开
+	DurationMs int64     `json:"durationMs"`
+	RequestID  string    `json:"requestId,omitempty"`
+}
+
+// newHistoryEntry 创建一条记录，info 为 nil 表示 URL 无法解析
+func newHistoryEntry(c *gin.Context, rawURL string, info *GitHubURLInfo) *HistoryEntry {
+	entry := &HistoryEntry{
+		ID:        newRequestID(),
+		Time:      time.Now().UTC(),
+		URL:       rawURL,
+		RequestID: requestID(c),
+	}
+	entry.setURLInfo(info)
+	return entry
+}
+
+// setURLInfo 记录从 URL 中解析出的仓库、ref、文件和行号
+func (e *HistoryEntry) setURLInfo(info *GitHubURLInfo) {
+	if info == nil {
+		return
+	}
+	e.Repo = info.Owner + "/" + info.Repo
+	e.Type, e.Ref, e.PRNumber = info.Type, info.Branch, info.PRNumber
+	e.FilePath, e.Line = info.FilePath, info.Line
+}
+
+// setRequest 记录请求中指定的文件、行号和目标模式，请求中的值优先于 URL
+func (e *HistoryEntry) setRequest(req *OpenRequest) {
+	if req.FilePath != "" {
+		e.FilePath = req.FilePath
+	}
+	if req.Line > 0 {
+		e.Line = req.Line
+	}
+	e.Column, e.Target = req.Column, req.Target
+}
+
+// finish 根据 respondError 记录的错误码设置结果和耗时
+func (e *HistoryEntry) finish(c *gin.Context, start time.Time) {
+	e.Status = "ok"
+	if code := c.GetString(errorCodeKey); code != "" {
+		e.Status, e.Code, e.Message = "error", ErrorCode(code), c.GetString(errorMessageKey)
+	}
+	e.DurationMs = time.Since(start).Milliseconds()
+}
+
+// Favorite 是收藏的仓库
+type Favorite struct {
+	Repo    string    `json:"repo"`           // owner/repo
+	URL     string    `json:"url"`            // 打开收藏时使用的 URL
+	Name    string    `json:"name,omitempty"` // 显示名称
+	IDE     string    `json:"ide,omitempty"`  // 打开时使用的 IDE，为空时按配置选择
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// historyMu 保护历史记录和收藏文件的读写
+var historyMu sync.Mutex
+
+// historyPath 返回打开历史文件，与配置文件和仓库缓存一起放在 ~/.github-browser 下
+func historyPath() string {
+	return filepath.Join(filepath.Dir(ConfigPath()), "history.json")
+}
+
+// favoritesPath 返回收藏文件
+func favoritesPath() string {
+	return filepath.Join(filepath.Dir(ConfigPath()), "favorites.json")
+}
+
+// readStateFile 读取 JSON 文件，文件不存在时保持 v 不变
+func readStateFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// writeStateFile 写入 JSON 文件，其中包含私有仓库名称，只允许当前用户读写
+func writeStateFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// loadHistory 读取历史记录，按时间从旧到新排列
+func loadHistory() ([]*HistoryEntry, error) {
+	entries := []*HistoryEntry{}
+	err := readStateFile(historyPath(), &entries)
+	return entries, err
+}
+
+// pruneHistory 删除超过保留时间的记录，并只保留最近的 maxEntries 条
+func pruneHistory(entries []*HistoryEntry, hc *HistoryConfig, now time.Time) []*HistoryEntry {
+	cutoff := now.Add(-hc.maxAge())
+	first := 0
+	for first < len(entries) && entries[first].Time.Before(cutoff) {
+		first++
+	}
+	if n := len(entries) - first; n > hc.maxEntries() {
+		first += n - hc.maxEntries()
+	}
+	return entries[first:]
+}
+
+// recordHistory 追加打开记录并按保留策略清理，记录失败只写日志
+func (s *Service) recordHistory(entries ...*HistoryEntry) {
+	if !s.config.History.enabled() || len(entries) == 0 {
+		return
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	history, err := loadHistory()
+	if err == nil {
+		history = pruneHistory(append(history, entries...), s.config.History, time.Now())
+		err = writeStateFile(historyPath(), history)
+	}
+	if err != nil {
+		s.log.Warn("Failed to record open history", "error", err)
+	}
+}
+
+// HistoryFilter 是 GET /history 的查询条件
+type HistoryFilter struct {
+	Repo     string // owner/repo 或 owner
+	Query    string // 在 URL、仓库和文件路径中查找，不区分大小写
+	Status   string // ok 或 error
+	IDE      string
+	Since    time.Time // 只返回该时间之后的记录
+	Distinct bool      // 每个仓库只返回最近的一条，用于"最近打开"
+}
+
+// match 判断记录是否满足查询条件
+func (f *HistoryFilter) match(e *HistoryEntry) bool {
+	if f.Repo != "" && !strings.EqualFold(e.Repo, f.Repo) {
+		// 不含 / 时匹配该 owner 的所有仓库
+		owner, _, _ := strings.Cut(e.Repo, "/")
+		if strings.Contains(f.Repo, "/") || !strings.EqualFold(owner, f.Repo) {
+			return false
+		}
+	}
+	if f.Query != "" {
+		q := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(e.URL+"\n"+e.Repo+"\n"+e.FilePath), q) {
+			return false
+		}
+	}
+	if f.Status != "" && e.Status != f.Status {
+		return false
+	}
+	if f.IDE != "" && e.IDE != f.IDE {
+		return false
+	}
+	return f.Since.IsZero() || !e.Time.Before(f.Since)
+}
+
+// filter 返回满足条件的记录，按时间从新到旧排列
+func (f *HistoryFilter) filter(entries []*HistoryEntry) []*HistoryEntry {
+	matched := []*HistoryEntry{}
+	seen := map[string]bool{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if !f.match(e) {
+			continue
+		}
+		if f.Distinct {
+			key := strings.ToLower(e.Repo)
+			if key == "" {
+				key = e.URL
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		matched = append(matched, e)
+	}
+	return matched
+}
+
+// parseHistoryFilter 从查询参数中读取查询条件
+func parseHistoryFilter(c *gin.Context) (*HistoryFilter, error) {
+	f := &HistoryFilter{
+		Repo:   c.Query("repo"),
+		Query:  c.Query("q"),
+		Status: c.Query("status"),
+		IDE:    c.Query("ide"),
+	}
+	switch f.Status {
+	case "", "ok", "error":
+	default:
+		return nil, fmt.Errorf("invalid status %q, must be ok or error", f.Status)
+	}
+	if v := c.Query("since"); v != "" {
+		// 时间点，或 "7d"、"24h" 这样的相对时间
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			f.Since = t
+		} else if age, err := parseAge(v); err == nil {
+			f.Since = time.Now().Add(-age)
+		} else {
+			return nil, fmt.Errorf("invalid since %q, use RFC 3339 time or a duration such as 7d", v)
+		}
+	}
+	if v := c.Query("distinct"); v != "" {
+		distinct, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid distinct %q", v)
+		}
+		f.Distinct = distinct
+	}
+	return f, nil
+}
+
+// queryInt 读取非负整数查询参数，未设置时返回 fallback
+func queryInt(c *gin.Context, name string, fallback int) (int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return n, nil
+}
+
+// HistoryResponse 是 GET /history 的响应
+type HistoryResponse struct {
+	Status  string          `json:"status"`
+	Entries []*HistoryEntry `json:"entries"`
+	Total   int             `json:"total"` // 满足条件的记录总数
+	Offset  int             `json:"offset"`
+	Limit   int             `json:"limit"`
+}
+
+// handleListHistory 按时间从新到旧返回打开历史，支持过滤和分页
+func (s *Service) handleListHistory(c *gin.Context) {
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, err.Error(), err))
+		return
+	}
+	limit, err := queryInt(c, "limit", defaultHistoryQueryLimit)
+	if err != nil {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, err.Error(), err))
+		return
+	}
+	limit = min(limit, maxHistoryQueryLimit)
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, err.Error(), err))
+		return
+	}
+
+	historyMu.Lock()
+	entries, err := loadHistory()
+	historyMu.Unlock()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	// 保留策略变化后，查询结果立即生效，文件在下次记录时清理
+	entries = filter.filter(pruneHistory(entries, s.config.History, time.Now()))
+
+	page := entries[min(offset, len(entries)):min(offset+limit, len(entries))]
+	c.JSON(200, HistoryResponse{
+		Status:  "ok",
+		Entries: page,
+		Total:   len(entries),
+		Offset:  offset,
+		Limit:   limit,
+	})
+}
+
+// ClearHistoryResponse 是 DELETE /history 的响应
+type ClearHistoryResponse struct {
+	Status  string `json:"status"`
+	Removed int    `json:"removed"` // 删除的记录数
+}
+
+// handleClearHistory 删除打开历史，指定 repo 时只删除该仓库的记录
+func (s *Service) handleClearHistory(c *gin.Context) {
+	repo := c.Query("repo")
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	entries, err := loadHistory()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	kept := []*HistoryEntry{}
+	if repo != "" {
+		filter := &HistoryFilter{Repo: repo}
+		for _, e := range entries {
+			if !filter.match(e) {
+				kept = append(kept, e)
+			}
+		}
+	}
+	if err := writeStateFile(historyPath(), kept); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(200, ClearHistoryResponse{Status: "ok", Removed: len(entries) - len(kept)})
+}
+
+// ReopenRequest 是 POST /history/:id/reopen 的请求，字段覆盖记录中的值
+type ReopenRequest struct {
+	IDE    string `json:"ide"`
+	Target string `json:"target"`
+}
+
+// handleReopen 按历史记录中的 URL、文件、行号和 IDE 再次打开，结果作为新记录写入历史
+func (s *Service) handleReopen(c *gin.Context) {
+	var override ReopenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&override); err != nil {
+			respondError(c, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid request: %v", err), err))
+			return
+		}
+	}
+
+	historyMu.Lock()
+	entries, err := loadHistory()
+	historyMu.Unlock()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	var entry *HistoryEntry
+	for _, e := range entries {
+		if e.ID == c.Param("id") {
+			entry = e
+		}
+	}
+	if entry == nil {
+		respondError(c, newServiceError(ErrCodeNotFound, "History entry not found: "+c.Param("id"), nil))
+		return
+	}
+
+	req := OpenRequest{
+		URL:      entry.URL,
+		IDE:      entry.IDE,
+		FilePath: entry.FilePath,
+		Line:     entry.Line,
+		Column:   entry.Column,
+		Target:   entry.Target,
+	}
+	if override.IDE != "" {
+		req.IDE = override.IDE
+	}
+	if override.Target != "" {
+		req.Target = override.Target
+	}
+	s.open(c, &req)
+}
+
+// loadFavorites 读取收藏，按添加顺序排列
+func loadFavorites() ([]*Favorite, error) {
+	favorites := []*Favorite{}
+	err := readStateFile(favoritesPath(), &favorites)
+	return favorites, err
+}
+
+// FavoriteRequest 是 PUT /favorites 的请求
+type FavoriteRequest struct {
+	URL  string `json:"url" binding:"required"`
+	Name string `json:"name"`
+	IDE  string `json:"ide"`
+}
+
+// FavoritesResponse 是 GET /favorites 的响应
+type FavoritesResponse struct {
+	Status    string      `json:"status"`
+	Favorites []*Favorite `json:"favorites"`
+	Count     int         `json:"count"`
+}
+
+// FavoriteResponse 是 PUT /favorites 的响应
+type FavoriteResponse struct {
+	Status   string    `json:"status"`
+	Favorite *Favorite `json:"favorite"`
+}
+
+// DeleteFavoriteResponse 是 DELETE /favorites 的响应
+type DeleteFavoriteResponse struct {
+	Status  string `json:"status"`
+	Removed string `json:"removed"` // 取消收藏的 owner/repo
+}
+
+// handleListFavorites 返回收藏的仓库
+func (s *Service) handleListFavorites(c *gin.Context) {
+	historyMu.Lock()
+	favorites, err := loadFavorites()
+	historyMu.Unlock()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(200, FavoritesResponse{Status: "ok", Favorites: favorites, Count: len(favorites)})
+}
+
+// handlePutFavorite 收藏仓库，同一仓库已收藏时更新 URL、名称和 IDE
+func (s *Service) handlePutFavorite(c *gin.Context) {
+	var req FavoriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid request: %v", err), err))
+		return
+	}
+	info, err := ParseGitHubURL(req.URL)
+	if err != nil {
+		respondError(c, fmt.Errorf("Invalid GitHub URL: %w", err))
+		return
+	}
+	if req.IDE != "" {
+		if _, ok := lookupIDE(req.IDE, s.config.CustomIDEs); !ok {
+			respondError(c, newServiceError(ErrCodeUnsupportedIDE, "unsupported IDE: "+req.IDE, nil))
+			return
+		}
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	favorites, err := loadFavorites()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	repo := info.Owner + "/" + info.Repo
+	var favorite *Favorite
+	for _, f := range favorites {
+		if strings.EqualFold(f.Repo, repo) {
+			favorite = f
+		}
+	}
+	if favorite == nil {
+		favorite = &Favorite{Repo: repo, AddedAt: time.Now().UTC()}
+		favorites = append(favorites, favorite)
+	}
+	favorite.URL, favorite.Name, favorite.IDE = req.URL, req.Name, req.IDE
+
+	if err := writeStateFile(favoritesPath(), favorites); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(200, FavoriteResponse{Status: "ok", Favorite: favorite})
+}
+
+// handleDeleteFavorite 取消收藏，仓库由查询参数 repo（owner/repo）或 url 指定
+func (s *Service) handleDeleteFavorite(c *gin.Context) {
+	repo := c.Query("repo")
+	if u := c.Query("url"); repo == "" && u != "" {
+		info, err := ParseGitHubURL(u)
+		if err != nil {
+			respondError(c, fmt.Errorf("Invalid GitHub URL: %w", err))
+			return
+		}
+		repo = info.Owner + "/" + info.Repo
+	}
+	if repo == "" {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, "repo or url is required", nil))
+		return
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	favorites, err := loadFavorites()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	kept := []*Favorite{}
+	for _, f := range favorites {
+		if !strings.EqualFold(f.Repo, repo) {
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) == len(favorites) {
+		respondError(c, newServiceError(ErrCodeNotFound, "Favorite not found: "+repo, nil))
+		return
+	}
+	if err := writeStateFile(favoritesPath(), kept); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(200, DeleteFavoriteResponse{Status: "ok", Removed: repo})
+}
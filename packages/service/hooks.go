@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Hook 是仓库准备好之后执行的初始化命令，如 `go mod download`、`pnpm install`
+type Hook struct {
+	Name    string            `json:"name,omitempty"`
+	Stage   string            `json:"stage"`             // after-clone、after-checkout 或 before-launch
+	Repo    string            `json:"repo,omitempty"`    // owner/repo 模式，与 ideRules 相同；为空匹配所有仓库
+	Markers []string          `json:"markers,omitempty"` // 仓库根目录下存在其中任一文件时才执行，如 "go.mod"
+	Command []string          `json:"command,omitempty"` // 直接执行的命令及参数
+	Shell   string            `json:"shell,omitempty"`   // 通过 sh -c 执行的命令，与 command 二选一
+	Env     map[string]string `json:"env,omitempty"`     // 额外的环境变量
+	Dir     string            `json:"dir,omitempty"`     // 相对仓库根目录的工作目录，不能指向仓库之外
+	Timeout string            `json:"timeout,omitempty"` // 超时时间，如 "2m"，默认 5 分钟
+	// Background 为 true 时不等待命令结束，IDE 会立即打开
+	Background bool `json:"background,omitempty"`
+}
+
+const (
+	HookAfterClone    = "after-clone"    // 首次克隆之后
+	HookAfterCheckout = "after-checkout" // 克隆或切换分支、PR 之后
+	HookBeforeLaunch  = "before-launch"  // 每次启动 IDE 之前
+
+	defaultHookTimeout = 5 * time.Minute
+	hookOutputLimit    = 4096 // 响应中保留的输出长度
+)
+
+// HookResult 是一次 hook 执行的结果
+type HookResult struct {
+	Name       string `json:"name"`
+	Stage      string `json:"stage"`
+	Status     string `json:"status"` // ok、failed、timeout 或 background
+	ExitCode   int    `json:"exitCode,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+	Output     string `json:"output,omitempty"` // 输出的最后一部分，已脱敏
+	Background bool   `json:"background,omitempty"`
+}
+
+// label 返回 hook 用于日志和结果的名称
+func (h *Hook) label() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	if h.Shell != "" {
+		return h.Shell
+	}
+	return strings.Join(h.Command, " ")
+}
+
+// timeout 返回 hook 的超时时间
+func (h *Hook) timeout() time.Duration {
+	if d, err := time.ParseDuration(h.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultHookTimeout
+}
+
+// matches 判断 hook 是否适用于该仓库
+func (h *Hook) matches(info *GitHubURLInfo, repoPath string) bool {
+	if h.Repo != "" && !matchRepoPattern(h.Repo, info.Owner, info.Repo) {
+		return false
+	}
+	if len(h.Markers) == 0 {
+		return true
+	}
+	for _, marker := range h.Markers {
+		if _, err := os.Stat(filepath.Join(repoPath, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 检查 hook 配置是否有效
+func (h Hook) validate() error {
+	switch h.Stage {
+	case HookAfterClone, HookAfterCheckout, HookBeforeLaunch:
+	default:
+		return fmt.Errorf("stage: must be %s, %s or %s", HookAfterClone, HookAfterCheckout, HookBeforeLaunch)
+	}
+	if (len(h.Command) == 0) == (h.Shell == "") {
+		return fmt.Errorf("exactly one of command or shell is required")
+	}
+	if h.Repo != "" {
+		if _, err := path.Match(h.Repo, ""); err != nil {
+			return fmt.Errorf("repo: invalid pattern %q", h.Repo)
+		}
+	}
+	if h.Timeout != "" {
+		if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("timeout: invalid duration %q", h.Timeout)
+		}
+	}
+	if h.Dir != "" && (filepath.IsAbs(h.Dir) || !filepath.IsLocal(h.Dir)) {
+		return fmt.Errorf("dir: must be a relative path inside the repository")
+	}
+	for name := range h.Env {
+		if name == "" || strings.Contains(name, "=") {
+			return fmt.Errorf("env: invalid variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// matchHooks 返回适用于该仓库、属于给定阶段的 hook，保持配置中的顺序
+func (s *Service) matchHooks(info *GitHubURLInfo, repoPath string, stages []string) []*Hook {
+	var matched []*Hook
+	for _, stage := range stages {
+		for i := range s.config.Hooks {
+			hook := &s.config.Hooks[i]
+			if hook.Stage == stage && hook.matches(info, repoPath) {
+				matched = append(matched, hook)
+			}
+		}
+	}
+	return matched
+}
+
+// runHooks 依次执行各阶段的 hook，失败不会中断打开流程
+// 后台 hook 在单独的 goroutine 中按顺序执行，输出只写入日志
+func (s *Service) runHooks(info *GitHubURLInfo, repoPath string, stages []string) []HookResult {
+	var results []HookResult
+	var background []*Hook
+	for _, hook := range s.matchHooks(info, repoPath, stages) {
+		if hook.Background {
+			background = append(background, hook)
+			results = append(results, HookResult{Name: hook.label(), Stage: hook.Stage, Status: "background", Background: true})
+			continue
+		}
+		results = append(results, s.runHook(hook, info, repoPath))
+	}
+
+	if len(background) > 0 {
+		backgroundJobs.Add(1)
+		go func() {
+			defer backgroundJobs.Done()
+			for _, hook := range background {
+				s.runHook(hook, info, repoPath)
+			}
+		}()
+	}
+	return results
+}
+
+// runHook 执行单个 hook，输出逐行写入服务日志
+func (s *Service) runHook(hook *Hook, info *GitHubURLInfo, repoPath string) HookResult {
+	result := HookResult{Name: hook.label(), Stage: hook.Stage, Background: hook.Background}
+	start := time.Now()
+
+	dir, err := hookDir(repoPath, hook.Dir)
+	if err != nil {
+		result.Status, result.Output = "failed", err.Error()
+		s.log.Warn("Hook failed", "hook", sanitizeOutput(result.Name), "error", err)
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(jobsCtx, hook.timeout())
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if hook.Shell != "" {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook.Shell)
+	} else {
+		cmd = exec.CommandContext(ctx, expandPath(hook.Command[0]), hook.Command[1:]...)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GITHUB_BROWSER_REPO_PATH="+repoPath,
+		"GITHUB_BROWSER_OWNER="+info.Owner,
+		"GITHUB_BROWSER_REPO="+info.Repo,
+		"GITHUB_BROWSER_STAGE="+hook.Stage,
+	)
+	for k, v := range hook.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// 超时后结束整个进程组，子进程若仍占用输出管道，最多再等待一会儿
+	setProcessGroup(cmd)
+	cmd.WaitDelay = 5 * time.Second
+
+	s.log.Info("Running hook", "stage", hook.Stage, "hook", sanitizeOutput(result.Name))
+	output, err := cmd.CombinedOutput()
+	result.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		s.log.Info("Hook output", "hook", sanitizeOutput(result.Name), "line", sanitizeOutput(scanner.Text()))
+	}
+
+	result.Output = sanitizeOutput(tail(string(output), hookOutputLimit))
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.Status = "ok"
+		s.log.Info("Hook finished", "hook", sanitizeOutput(result.Name), "duration", result.Duration)
+		return result
+	case ctx.Err() == context.DeadlineExceeded:
+		result.Status = "timeout"
+	case errors.As(err, &exitErr):
+		result.Status, result.ExitCode = "failed", exitErr.ExitCode()
+	default:
+		result.Status = "failed"
+		result.Output = strings.TrimSpace(result.Output + "\n" + err.Error())
+	}
+	s.log.Warn("Hook failed", "hook", sanitizeOutput(result.Name), "status", result.Status, "duration", result.Duration, "error", err)
+	return result
+}
+
+// hookDir 返回 hook 的工作目录，解析符号链接后仍必须位于仓库之内
+func hookDir(repoPath, dir string) (string, error) {
+	root, err := filepath.EvalSymlinks(repoPath)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, dir))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return "", fmt.Errorf("hook directory %s is outside the repository", dir)
+	}
+	return resolved, nil
+}
+
+// tail 返回字符串最后 n 个字节
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "..." + s[len(s)-n:]
+}
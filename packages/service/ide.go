@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
-	"runtime"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -11,41 +14,153 @@ type ideConfig struct {
 	cmd      string
 	args     []string
 	gotoFlag string
+	// 以下字段只用于 config 中的自定义 IDE
+	lineArgs []string
+	env      map[string]string
+	dir      string
+	custom   bool
+	// terminal 表示需要在终端中运行的编辑器
+	terminal bool
+	// reuse 是复用已运行实例的方式：nvim、emacs、vscode
+	reuse string
+	// multiRoot 是同时打开多个仓库的方式：workspace（生成 .code-workspace）或 args（多个路径参数）
+	multiRoot string
+	// remote 是打开 dev container 或远程主机中仓库的方式：vscode 或 gateway
+	remote string
 }
 
 var ides = map[string]ideConfig{
-	"code":          {cmd: "code", args: []string{"$PATH"}, gotoFlag: "--goto"},
-	"vscode":        {cmd: "code", args: []string{"$PATH"}, gotoFlag: "--goto"},
-	"code-insiders": {cmd: "code-insiders", args: []string{"$PATH"}, gotoFlag: "--goto"},
-	"zed":           {cmd: "zed", args: []string{"$PATH:$LINE"}},
-	"cursor":        {cmd: "cursor", args: []string{"$PATH"}, gotoFlag: "--goto"},
-	"idea":          {cmd: "idea", args: []string{"--line", "$LINE", "$PATH"}},
-	"pycharm":       {cmd: "pycharm", args: []string{"--line", "$LINE", "$PATH"}},
-	"webstorm":      {cmd: "webstorm", args: []string{"--line", "$LINE", "$PATH"}},
-	"goland":        {cmd: "goland", args: []string{"--line", "$LINE", "$PATH"}},
+	"code":          {cmd: "code", args: []string{"$PATH"}, gotoFlag: "--goto", reuse: reuseVSCode, multiRoot: multiRootWorkspace, remote: remoteVSCode},
+	"vscode":        {cmd: "code", args: []string{"$PATH"}, gotoFlag: "--goto", reuse: reuseVSCode, multiRoot: multiRootWorkspace, remote: remoteVSCode},
+	"code-insiders": {cmd: "code-insiders", args: []string{"$PATH"}, gotoFlag: "--goto", reuse: reuseVSCode, multiRoot: multiRootWorkspace, remote: remoteVSCode},
+	"zed":           {cmd: "zed", args: []string{"$PATH:$LINE"}, multiRoot: multiRootArgs},
+	"cursor":        {cmd: "cursor", args: []string{"$PATH"}, gotoFlag: "--goto", reuse: reuseVSCode, multiRoot: multiRootWorkspace, remote: remoteVSCode},
+	"idea":          {cmd: "idea", args: []string{"--line", "$LINE", "$PATH"}, remote: remoteGateway},
+	"pycharm":       {cmd: "pycharm", args: []string{"--line", "$LINE", "$PATH"}, remote: remoteGateway},
+	"webstorm":      {cmd: "webstorm", args: []string{"--line", "$LINE", "$PATH"}, remote: remoteGateway},
+	"goland":        {cmd: "goland", args: []string{"--line", "$LINE", "$PATH"}, remote: remoteGateway},
 	"subl":          {cmd: "subl", args: []string{"$PATH:$LINE"}},
 	"sublime":       {cmd: "subl", args: []string{"$PATH:$LINE"}},
-	"nvim":          {cmd: "nvim", args: []string{"+$LINE", "$PATH"}},
-	"neovim":        {cmd: "nvim", args: []string{"+$LINE", "$PATH"}},
+	"nvim":          {cmd: "nvim", args: []string{"+$LINE", "$PATH"}, terminal: true, reuse: reuseNvim},
+	"neovim":        {cmd: "nvim", args: []string{"+$LINE", "$PATH"}, terminal: true, reuse: reuseNvim},
+	"vim":           {cmd: "vim", args: []string{"+$LINE", "$PATH"}, terminal: true},
+	"helix":         {cmd: "hx", args: []string{"$PATH:$LINE"}, terminal: true},
+	"hx":            {cmd: "hx", args: []string{"$PATH:$LINE"}, terminal: true},
+	"emacs":         {cmd: "emacs", args: []string{"+$LINE", "$PATH"}, reuse: reuseEmacs},
+	"emacs-nw":      {cmd: "emacs", args: []string{"-nw", "+$LINE", "$PATH"}, terminal: true, reuse: reuseEmacs},
 }
 
-// OpenInIDE 在指定的 IDE 中打开文件或目录
-func OpenInIDE(ideName, path string, line int) error {
+// ideTemplatePlaceholders 是自定义 IDE 参数模板中可用的占位符
+var ideTemplatePlaceholders = []string{"$PATH", "$LINE", "$COLUMN", "$REPO", "$FILE"}
+
+// OpenTarget 描述要在 IDE 中打开的位置
+type OpenTarget struct {
+	RepoPath string // 仓库根目录
+	Path     string // 要打开的文件或目录（绝对路径）
+	Line     int
+	Column   int
+	Mode     string // local、devcontainer 或 ssh，为空等同于 local
+}
+
+// remote 判断是否在 dev container 或远程主机中打开
+func (t OpenTarget) remote() bool {
+	return t.Mode == TargetDevContainer || t.Mode == TargetSSH
+}
+
+// lookupIDE 查找 IDE 配置，自定义 IDE 覆盖同名的内置 IDE
+func lookupIDE(ideName string, customIDEs map[string]CustomIDE) (ideConfig, bool) {
+	if custom, ok := customIDEs[ideName]; ok {
+		return ideConfig{
+			cmd:      custom.Command,
+			args:     custom.Args,
+			lineArgs: custom.LineArgs,
+			env:      custom.Env,
+			dir:      custom.Dir,
+			custom:   true,
+			terminal: custom.Terminal,
+		}, true
+	}
 	config, ok := ides[ideName]
+	return config, ok
+}
+
+// OpenInIDE 在指定的 IDE 中打开文件或目录
+// 优先交给已运行的编辑器实例，无法复用时启动新进程
+func OpenInIDE(ideName string, target OpenTarget, config *Config) (*LaunchResult, error) {
+	ide, ok := lookupIDE(ideName, config.CustomIDEs)
 	if !ok {
-		return fmt.Errorf("unsupported IDE: %s", ideName)
+		return nil, newServiceError(ErrCodeUnsupportedIDE, fmt.Sprintf("unsupported IDE: %s", ideName), nil)
+	}
+
+	// 远程目标总是交给 IDE 自己的远程连接处理
+	var reason string
+	if !target.remote() {
+		var result *LaunchResult
+		if result, reason = tryReuseInstance(ide, target, config.Reuse); result != nil {
+			return result, nil
+		}
+	}
+
+	cmd, err := BuildIDECommand(ideName, target, config)
+	if err != nil {
+		return nil, err
 	}
 
-	// 特殊处理 Neovim 在 macOS 下的情况
-	if (ideName == "nvim" || ideName == "neovim") && runtime.GOOS == "darwin" {
-		cmdStr := fmt.Sprintf("nvim %s", path)
-		if line > 0 {
-			cmdStr = fmt.Sprintf("nvim +%d %s", line, path)
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, newServiceError(ErrCodeIDENotInstalled, fmt.Sprintf("IDE command not found: %s", cmd.Args[0]), err)
 		}
-		return exec.Command("osascript", "-e",
-			fmt.Sprintf(`tell application "Terminal" to do script "%s"`, cmdStr)).Start()
+		return nil, err
+	}
+	if !target.remote() && reuseWindow(ide, config) {
+		return &LaunchResult{Mode: LaunchReused, Detail: "--reuse-window"}, nil
+	}
+	return &LaunchResult{Mode: LaunchNew, Detail: reason}, nil
+}
+
+// reuseWindow 判断是否让 VS Code 系列在已打开的窗口中打开，而不是新建窗口
+func reuseWindow(ide ideConfig, config *Config) bool {
+	return ide.reuse == reuseVSCode && (config.Reuse == nil || !config.Reuse.Disabled)
+}
+
+// BuildIDECommand 构造打开文件或目录的 IDE 命令，但不执行
+// 终端编辑器会被包装为在配置的终端（或 tmux）中运行
+func BuildIDECommand(ideName string, target OpenTarget, config *Config) (*exec.Cmd, error) {
+	ide, ok := lookupIDE(ideName, config.CustomIDEs)
+	if !ok {
+		return nil, newServiceError(ErrCodeUnsupportedIDE, fmt.Sprintf("unsupported IDE: %s", ideName), nil)
+	}
+
+	if target.remote() {
+		return buildRemoteIDECommand(ideName, ide, target, config)
 	}
 
+	var cmd *exec.Cmd
+	if ide.custom {
+		cmd = buildCustomIDECommand(ide, target)
+	} else {
+		cmd = buildBuiltinIDECommand(ide, target)
+	}
+
+	if reuseWindow(ide, config) {
+		cmd.Args = append([]string{cmd.Args[0], "--reuse-window"}, cmd.Args[1:]...)
+	}
+
+	if !ide.terminal {
+		return cmd, nil
+	}
+	wrapped, err := wrapInTerminal(cmd.Args, target.RepoPath, config.Terminal)
+	if err != nil {
+		return nil, err
+	}
+	wrapped.Env = cmd.Env
+	return wrapped, nil
+}
+
+// buildBuiltinIDECommand 按内置 IDE 的参数规则构造命令
+func buildBuiltinIDECommand(config ideConfig, target OpenTarget) *exec.Cmd {
+	path, line := target.Path, target.Line
+
 	var args []string
 	if line > 0 {
 		if config.gotoFlag != "" {
@@ -74,6 +189,51 @@ func OpenInIDE(ideName, path string, line int) error {
 		}
 	}
 
-	cmd := exec.Command(config.cmd, args...)
-	return cmd.Start()
+	return exec.Command(resolveIDECommand(config.cmd), args...)
+}
+
+// buildCustomIDECommand 展开自定义 IDE 的参数模板
+// 有行号且配置了 lineArgs 时使用 lineArgs，否则使用 args
+func buildCustomIDECommand(config ideConfig, target OpenTarget) *exec.Cmd {
+	templates := config.args
+	if target.Line > 0 && len(config.lineArgs) > 0 {
+		templates = config.lineArgs
+	}
+
+	args := make([]string, 0, len(templates))
+	for _, arg := range templates {
+		args = append(args, expandIDETemplate(arg, target))
+	}
+
+	cmd := exec.Command(resolveIDECommand(config.cmd), args...)
+	if config.dir != "" {
+		cmd.Dir = expandPath(expandIDETemplate(config.dir, target))
+	}
+	if len(config.env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range config.env {
+			cmd.Env = append(cmd.Env, k+"="+expandIDETemplate(v, target))
+		}
+	}
+	return cmd
+}
+
+// expandIDETemplate 替换模板中的占位符
+func expandIDETemplate(tmpl string, target OpenTarget) string {
+	column := target.Column
+	if column == 0 {
+		column = 1
+	}
+	file, err := filepath.Rel(target.RepoPath, target.Path)
+	if err != nil || file == "." {
+		file = ""
+	}
+
+	return strings.NewReplacer(
+		"$PATH", target.Path,
+		"$LINE", strconv.Itoa(target.Line),
+		"$COLUMN", strconv.Itoa(column),
+		"$REPO", target.RepoPath,
+		"$FILE", file,
+	).Replace(tmpl)
 }
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDEInfo 描述一个 IDE 在本机的安装情况
+type IDEInfo struct {
+	Name      string `json:"name"`
+	Command   string `json:"command"`
+	Custom    bool   `json:"custom"`
+	Available bool   `json:"available"`
+	Path      string `json:"path,omitempty"`    // 实际使用的可执行文件
+	Source    string `json:"source,omitempty"`  // 找到的位置：path、jetbrains-toolbox、flatpak、snap
+	Version   string `json:"version,omitempty"` // 仅对支持 --version 且不会启动界面的 IDE 探测
+}
+
+const (
+	IDESourcePath     = "path"
+	IDESourceToolbox  = "jetbrains-toolbox"
+	IDESourceFlatpak  = "flatpak"
+	IDESourceSnap     = "snap"
+	ideVersionTimeout = 3 * time.Second
+)
+
+// flatpakApps 是命令对应的 Flatpak 应用 ID，按优先级排列
+var flatpakApps = map[string][]string{
+	"code":     {"com.visualstudio.code"},
+	"zed":      {"dev.zed.Zed"},
+	"subl":     {"com.sublimetext.three"},
+	"nvim":     {"io.neovim.nvim"},
+	"idea":     {"com.jetbrains.IntelliJ-IDEA-Ultimate", "com.jetbrains.IntelliJ-IDEA-Community"},
+	"pycharm":  {"com.jetbrains.PyCharm-Professional", "com.jetbrains.PyCharm-Community"},
+	"goland":   {"com.jetbrains.GoLand"},
+	"webstorm": {"com.jetbrains.WebStorm"},
+}
+
+// snapApps 是命令对应的 Snap 命令名，按优先级排列
+var snapApps = map[string][]string{
+	"code":          {"code"},
+	"code-insiders": {"code-insiders"},
+	"subl":          {"subl", "sublime-text"},
+	"nvim":          {"nvim"},
+	"idea":          {"intellij-idea-ultimate", "intellij-idea-community"},
+	"pycharm":       {"pycharm-professional", "pycharm-community"},
+	"goland":        {"goland"},
+	"webstorm":      {"webstorm"},
+}
+
+// versionArgs 是可以安全获取版本号（不会打开窗口）的命令
+var versionArgs = map[string][]string{
+	"code":          {"--version"},
+	"code-insiders": {"--version"},
+	"cursor":        {"--version"},
+	"zed":           {"--version"},
+	"subl":          {"--version"},
+	"nvim":          {"--version"},
+}
+
+// toolboxScriptDirs 返回 JetBrains Toolbox 生成启动脚本的目录
+func toolboxScriptDirs() []string {
+	home := os.Getenv("HOME")
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{filepath.Join(home, "Library", "Application Support", "JetBrains", "Toolbox", "scripts")}
+	case "linux":
+		return []string{filepath.Join(home, ".local", "share", "JetBrains", "Toolbox", "scripts")}
+	default:
+		return nil
+	}
+}
+
+// flatpakExportDirs 返回 Flatpak 导出可执行文件的目录
+func flatpakExportDirs() []string {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	return []string{
+		filepath.Join(os.Getenv("HOME"), ".local", "share", "flatpak", "exports", "bin"),
+		"/var/lib/flatpak/exports/bin",
+	}
+}
+
+// findIDECommand 查找命令的可执行文件，依次检查 PATH、JetBrains Toolbox、Flatpak 和 Snap
+func findIDECommand(command string) (path, source string, ok bool) {
+	command = expandPath(command)
+	if p, err := exec.LookPath(command); err == nil {
+		return p, IDESourcePath, true
+	}
+	// 绝对路径或相对路径不做额外查找
+	if strings.ContainsRune(command, os.PathSeparator) {
+		return "", "", false
+	}
+
+	for _, dir := range toolboxScriptDirs() {
+		if p := filepath.Join(dir, command); isExecutable(p) {
+			return p, IDESourceToolbox, true
+		}
+	}
+	for _, dir := range flatpakExportDirs() {
+		for _, app := range flatpakApps[command] {
+			if p := filepath.Join(dir, app); isExecutable(p) {
+				return p, IDESourceFlatpak, true
+			}
+		}
+	}
+	if runtime.GOOS == "linux" {
+		for _, app := range snapApps[command] {
+			if p := filepath.Join("/snap/bin", app); isExecutable(p) {
+				return p, IDESourceSnap, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// isExecutable 判断路径是否为可执行文件
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
+
+// DetectIDE 探测单个 IDE 是否可用
+func DetectIDE(ideName string, customIDEs map[string]CustomIDE) IDEInfo {
+	info := IDEInfo{Name: ideName}
+	config, ok := lookupIDE(ideName, customIDEs)
+	if !ok {
+		return info
+	}
+	info.Command, info.Custom = config.cmd, config.custom
+
+	path, source, found := findIDECommand(config.cmd)
+	if !found {
+		return info
+	}
+	info.Available, info.Path, info.Source = true, path, source
+
+	if args, ok := versionArgs[config.cmd]; ok && !config.custom {
+		info.Version = probeVersion(path, args)
+	}
+	return info
+}
+
+// DetectIDEs 并发探测所有内置和自定义 IDE，按名称排序
+func DetectIDEs(customIDEs map[string]CustomIDE) []IDEInfo {
+	names := make(map[string]bool)
+	for name := range ides {
+		names[name] = true
+	}
+	for name := range customIDEs {
+		names[name] = true
+	}
+
+	result := make([]IDEInfo, 0, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			info := DetectIDE(name, customIDEs)
+			mu.Lock()
+			result = append(result, info)
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// probeVersion 运行版本命令，返回输出的第一行
+func probeVersion(path string, args []string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), ideVersionTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, args...).Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(line)
+}
+
+// ideAvailable 判断 IDE 是否已配置且能找到可执行文件
+func ideAvailable(ideName string, customIDEs map[string]CustomIDE) bool {
+	config, ok := lookupIDE(ideName, customIDEs)
+	if !ok {
+		return false
+	}
+	_, _, found := findIDECommand(config.cmd)
+	return found
+}
+
+// resolveIDECommand 返回启动时使用的命令
+// 不在 PATH 中但能在 Toolbox、Flatpak、Snap 目录找到时返回完整路径
+func resolveIDECommand(command string) string {
+	if path, source, ok := findIDECommand(command); ok && source != IDESourcePath {
+		return path
+	}
+	return expandPath(command)
+}
+
+// chooseIDE 在请求的 IDE 不可用时，返回偏好列表中第一个可用的 IDE
+// 没有可用的替代时返回原 IDE，由启动时报告错误
+func chooseIDE(ideName string, preference []string, customIDEs map[string]CustomIDE) (chosen string, fallbackFrom string) {
+	if len(preference) == 0 || ideAvailable(ideName, customIDEs) {
+		return ideName, ""
+	}
+	for _, candidate := range preference {
+		if candidate != ideName && ideAvailable(candidate, customIDEs) {
+			return candidate, ideName
+		}
+	}
+	return ideName, ""
+}
@@ -14,7 +14,8 @@ esac
 
 # 构建二进制文件
 echo "📦 Building service..."
-go build -o github-browser-service
+VERSION="$(git describe --tags --always --dirty 2>/dev/null || echo dev)"
+go build -ldflags "-X main.version=${VERSION}" -o github-browser-service
 
 # 安装到 /usr/local/bin
 echo "📥 Installing to /usr/local/bin..."
@@ -39,30 +40,59 @@ fi
 
 # 创建 systemd 服务（Linux）
 if [ "$MACHINE" = "linux" ]; then
-    echo "🔧 Creating systemd service..."
+    PORT="$(/usr/local/bin/github-browser-service --local config get port 2>/dev/null || echo 9527)"
+
+    echo "🔧 Creating systemd socket and service..."
+    # socket activation：由 systemd 监听端口，第一个请求到来时再启动服务
+    sudo tee /etc/systemd/system/github-browser.socket > /dev/null <<EOF
+[Unit]
+Description=GitHub Browser Service socket
+
+[Socket]
+ListenStream=${PORT}
+
+[Install]
+WantedBy=sockets.target
+EOF
+
+    # Type=notify：服务就绪后通知 systemd，并定期发送 watchdog 心跳
+    # KillMode=mixed：停止时只向主进程发送 SIGTERM，由服务等待进行中的请求、必要时取消 git 子进程
+    # TimeoutStopSec 需大于配置中的 shutdownTimeout（默认 30 秒）
     sudo tee /etc/systemd/system/github-browser.service > /dev/null <<EOF
 [Unit]
 Description=GitHub Browser Service
 After=network.target
+Requires=github-browser.socket
 
 [Service]
-Type=simple
+Type=notify
+NotifyAccess=main
 User=$USER
-ExecStart=/usr/local/bin/github-browser-service
-Restart=always
+# 与用户会话相同的 XDG_RUNTIME_DIR，服务和命令行使用同一个默认 Unix socket 路径
+Environment=XDG_RUNTIME_DIR=/run/user/$(id -u)
+ExecStart=/usr/local/bin/github-browser-service serve
+Restart=on-failure
 RestartSec=5
+WatchdogSec=30
+KillMode=mixed
+TimeoutStopSec=45
 
 [Install]
 WantedBy=multi-user.target
+Also=github-browser.socket
 EOF
 
-    echo "🔄 Enabling and starting service..."
+    echo "🔄 Enabling socket..."
     sudo systemctl daemon-reload
-    sudo systemctl enable github-browser
-    sudo systemctl start github-browser
-    
-    echo "✅ Service installed and started!"
-    echo "📊 Check status: sudo systemctl status github-browser"
+    sudo systemctl enable --now github-browser.socket
+    # 正在运行的旧版本服务重启后改用 socket 传入的端口
+    sudo systemctl try-restart github-browser.service
+
+    echo "🔗 Registering github-browser:// link handler..."
+    /usr/local/bin/github-browser-service url-handler install || echo "⚠️  Failed to register link handler (is xdg-utils installed?)"
+
+    echo "✅ Service installed! It starts automatically on the first request."
+    echo "📊 Check status: sudo systemctl status github-browser.socket github-browser"
 fi
 
 # 创建 LaunchAgent（macOS）
@@ -94,7 +124,7 @@ EOF
 
     echo "🔄 Loading LaunchAgent..."
     launchctl load ~/Library/LaunchAgents/com.github-browser.service.plist
-    
+
     echo "✅ Service installed and started!"
     echo "📊 Check logs: tail -f ~/.github-browser/service.log"
 fi
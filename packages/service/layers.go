@@ -0,0 +1,582 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 配置按以下顺序合并，后面的覆盖前面的：
+//
+//	默认值 < 系统配置 < 团队配置（include 引用的文件）< 用户配置 < 环境变量
+//
+// 对象按字段递归合并；pathMappings、ideRules 和 hooks 把上层的条目放在下层之前，
+// pathMappings 中 pattern 相同的条目以上层为准；其他数组整体替换
+const (
+	LayerDefault = "default"
+	LayerSystem  = "system"
+	LayerTeam    = "team"
+	LayerUser    = "user"
+	LayerEnv     = "env"
+
+	defaultSystemConfigPath = "/etc/github-browser/config.json"
+	maxIncludeDepth         = 4
+)
+
+// mergedLists 是跨层合并而不是替换的数组，值为用于去重的字段
+var mergedLists = map[string][]string{
+	"pathMappings": {"host", "pattern", "regex"},
+	"ideRules":     nil,
+	"hooks":        nil,
+}
+
+// configEnvVars 是可以覆盖配置的环境变量
+// 只接受列出的变量，GITHUB_BROWSER_ADDR 和传给 hook 的变量不会被当作配置
+var configEnvVars = []struct {
+	name string
+	key  string // 以点分隔的配置字段
+	kind string // string、int 或 bool
+}{
+	{"GITHUB_BROWSER_PORT", "port", "int"},
+	{"GITHUB_BROWSER_DEFAULT_IDE", "defaultIDE", "string"},
+	{"GITHUB_BROWSER_GITHUB_TOKEN", "githubToken", "string"},
+	{"GITHUB_BROWSER_CACHE_DIR", "cacheDir", "string"},
+	{"GITHUB_BROWSER_GIT_BACKEND", "gitBackend", "string"},
+	{"GITHUB_BROWSER_DEV_CONTAINER", "devContainer", "bool"},
+	{"GITHUB_BROWSER_REMOTE_HOST", "remote.host", "string"},
+	{"GITHUB_BROWSER_LOG_LEVEL", "log.level", "string"},
+}
+
+// SystemConfigPath 返回系统配置文件路径，可通过 GITHUB_BROWSER_SYSTEM_CONFIG 修改
+func SystemConfigPath() string {
+	if path := os.Getenv("GITHUB_BROWSER_SYSTEM_CONFIG"); path != "" {
+		return path
+	}
+	return defaultSystemConfigPath
+}
+
+// configLayer 是参与合并的一个配置来源
+type configLayer struct {
+	Name    string `json:"name"`             // default、system、team、user 或 env
+	Source  string `json:"source,omitempty"` // 文件路径或环境变量名
+	raw     map[string]interface{}
+	data    []byte // 文件原始内容，迁移时用于备份
+	version int    // 文件原来的版本
+}
+
+// origin 返回用于 explain 和错误信息的来源描述
+func (l *configLayer) origin() string {
+	if l.Source == "" {
+		return l.Name
+	}
+	return l.Name + ":" + l.Source
+}
+
+// layeredConfig 是合并后的配置及每个值的来源
+type layeredConfig struct {
+	config  *Config
+	layers  []*configLayer
+	origins map[string]string // 字段路径 → 来源，数组中合并的条目记录到下标
+}
+
+// userLayer 返回用户配置层，用户配置文件不存在时返回 nil
+func (lc *layeredConfig) userLayer() *configLayer {
+	for _, layer := range lc.layers {
+		if layer.Name == LayerUser {
+			return layer
+		}
+	}
+	return nil
+}
+
+// loadLayeredConfig 读取并合并所有配置层
+// 用户配置文件不存在时创建，旧版本的用户配置迁移后写回；系统和团队配置只在内存中迁移
+func loadLayeredConfig() (*layeredConfig, error) {
+	configPath := ConfigPath()
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		if err := writeUserConfig(map[string]interface{}{"version": CurrentConfigVersion}); err != nil {
+			return nil, err
+		}
+	}
+
+	layers, _, err := readConfigLayers()
+	if err != nil {
+		return nil, err
+	}
+	lc, err := resolveLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+
+	if user := lc.userLayer(); user != nil && user.version < CurrentConfigVersion {
+		backup := fmt.Sprintf("%s.v%d.bak", configPath, user.version)
+		if err := os.WriteFile(backup, user.data, 0600); err != nil {
+			return nil, err
+		}
+		if err := writeUserConfig(user.raw); err != nil {
+			return nil, err
+		}
+		slog.Info("Migrated config", "from", user.version, "to", CurrentConfigVersion, "backup", backup)
+	}
+	return lc, nil
+}
+
+// layerReader 按顺序读取配置文件，记录所有输入的摘要用于检测变化
+type layerReader struct {
+	team    []*configLayer
+	digest  hash.Hash
+	visited map[string]bool
+	errs    ValidationError
+}
+
+// readConfigLayers 读取所有配置层并返回输入内容的摘要
+// 文件无法读取或不是 JSON 时返回错误；字段错误在 resolveLayers 中统一检查
+func readConfigLayers() ([]*configLayer, [sha256.Size]byte, error) {
+	r := &layerReader{digest: sha256.New(), visited: map[string]bool{}}
+
+	defaults, err := configToMap(DefaultConfig())
+	if err != nil {
+		return nil, [sha256.Size]byte{}, err
+	}
+	layers := []*configLayer{{Name: LayerDefault, raw: defaults, version: CurrentConfigVersion}}
+
+	system, err := r.file(LayerSystem, SystemConfigPath(), false, 0)
+	if err == nil {
+		var user *configLayer
+		if user, err = r.file(LayerUser, ConfigPath(), false, 0); err == nil {
+			if system != nil {
+				layers = append(layers, system)
+			}
+			layers = append(layers, r.team...)
+			if user != nil {
+				layers = append(layers, user)
+			}
+			layers = append(layers, r.env()...)
+		}
+	}
+
+	var digest [sha256.Size]byte
+	copy(digest[:], r.digest.Sum(nil))
+	if err == nil {
+		err = r.errs.err()
+	}
+	return layers, digest, err
+}
+
+// file 读取一个配置文件，include 引用的文件作为团队配置层加入
+func (r *layerReader) file(name, path string, required bool, depth int) (*configLayer, error) {
+	r.visited[filepath.Clean(path)] = true
+	data, err := os.ReadFile(path)
+	fmt.Fprintf(r.digest, "%s\x00%d\x00", path, len(data))
+	r.digest.Write(data)
+	if os.IsNotExist(err) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		if err == nil {
+			err = fmt.Errorf("config must be a JSON object")
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	layer := &configLayer{Name: name, Source: path, raw: raw, data: data}
+	if layer.version, err = migrateConfig(raw); err != nil {
+		r.errs.addFrom(layer, err)
+		return layer, nil
+	}
+
+	includes, err := includePaths(raw, path)
+	if err != nil {
+		r.errs.addFrom(layer, err)
+		return layer, nil
+	}
+	for _, include := range includes {
+		if r.visited[include] {
+			continue
+		}
+		if depth >= maxIncludeDepth {
+			r.errs.addFrom(layer, fmt.Errorf("include: too many nested includes at %s", include))
+			continue
+		}
+		// 被引用的文件优先级低于引用它的团队配置
+		team, err := r.file(LayerTeam, include, true, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if team != nil {
+			r.team = append(r.team, team)
+		}
+	}
+	return layer, nil
+}
+
+// includePaths 返回配置中 include 引用的文件，相对路径相对于该配置文件所在目录
+func includePaths(raw map[string]interface{}, path string) ([]string, error) {
+	value, ok := raw["include"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("include: must be an array of file paths")
+	}
+	paths := make([]string, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("include[%d]: must be a file path", i)
+		}
+		s = expandPath(s)
+		if !filepath.IsAbs(s) {
+			s = filepath.Join(filepath.Dir(path), s)
+		}
+		paths[i] = filepath.Clean(s)
+	}
+	return paths, nil
+}
+
+// env 把设置了的环境变量转换为配置层，每个变量单独一层以便 explain 指出来源
+func (r *layerReader) env() []*configLayer {
+	var layers []*configLayer
+	for _, v := range configEnvVars {
+		value := os.Getenv(v.name)
+		if value == "" {
+			continue
+		}
+		fmt.Fprintf(r.digest, "%s=%s\x00", v.name, value)
+
+		layer := &configLayer{Name: LayerEnv, Source: v.name, raw: map[string]interface{}{}, version: CurrentConfigVersion}
+		var typed interface{} = value
+		switch v.kind {
+		case "int":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				r.errs.addFrom(layer, fmt.Errorf("%s: must be a number", v.key))
+				continue
+			}
+			typed = n
+		case "bool":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				r.errs.addFrom(layer, fmt.Errorf("%s: must be true or false", v.key))
+				continue
+			}
+			typed = b
+		}
+		setConfigKey(layer.raw, v.key, typed)
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+// resolveLayers 校验每一层并合并为最终配置
+func resolveLayers(layers []*configLayer) (*layeredConfig, error) {
+	errs := &ValidationError{}
+	for _, layer := range layers[1:] {
+		data, err := json.Marshal(layer.raw)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := decodeConfig(data); err != nil {
+			errs.addFrom(layer, err)
+		}
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	merged, origins := mergeLayers(layers)
+	merged["version"] = CurrentConfigVersion
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	config, err := decodeConfig(data)
+	if err == nil {
+		err = config.Validate()
+	}
+	if err != nil {
+		// 合并后的错误指向提供该值的配置层
+		errs := fieldErrors(err)
+		for i := range errs {
+			errs[i].Source = originOf(origins, errs[i].Field)
+		}
+		return nil, err
+	}
+	return &layeredConfig{config: config, layers: layers, origins: origins}, nil
+}
+
+// mergeLayers 按顺序合并配置层，返回合并结果和每个字段的来源
+func mergeLayers(layers []*configLayer) (map[string]interface{}, map[string]string) {
+	merged := map[string]interface{}{}
+	origins := map[string]string{}
+	for _, layer := range layers {
+		mergeLayer(merged, layer.raw, "", layer.origin(), origins)
+	}
+	return merged, origins
+}
+
+// mergeLayer 把一层配置合并到 target，null 表示删除下层的值
+func mergeLayer(target, src map[string]interface{}, prefix, origin string, origins map[string]string) {
+	for key, value := range src {
+		if prefix == "" && (key == "include" || key == "version" || key == "$schema") {
+			continue
+		}
+		path := joinField(prefix, key)
+		if value == nil {
+			delete(target, key)
+			clearOrigins(origins, path)
+			continue
+		}
+		if object, ok := value.(map[string]interface{}); ok {
+			sub, ok := target[key].(map[string]interface{})
+			if !ok {
+				sub = map[string]interface{}{}
+				clearOrigins(origins, path)
+			}
+			target[key] = sub
+			mergeLayer(sub, object, path, origin, origins)
+			continue
+		}
+		if list, ok := value.([]interface{}); ok {
+			if dedupeKeys, merged := mergedLists[path]; merged {
+				target[key] = mergeList(list, target[key], path, dedupeKeys, origin, origins)
+				continue
+			}
+		}
+		target[key] = value
+		clearOrigins(origins, path)
+		origins[path] = origin
+	}
+}
+
+// mergeList 把上层的条目放在下层之前，dedupeKeys 字段都相同的下层条目被忽略
+func mergeList(higher []interface{}, lowerValue interface{}, path string, dedupeKeys []string, origin string, origins map[string]string) []interface{} {
+	lower, _ := lowerValue.([]interface{})
+	lowerOrigins := make([]string, len(lower))
+	for i := range lower {
+		lowerOrigins[i] = origins[fmt.Sprintf("%s[%d]", path, i)]
+	}
+	clearOrigins(origins, path)
+
+	result := make([]interface{}, 0, len(higher)+len(lower))
+	seen := map[string]bool{}
+	for _, item := range higher {
+		origins[fmt.Sprintf("%s[%d]", path, len(result))] = origin
+		result = append(result, item)
+		if object, ok := item.(map[string]interface{}); ok && dedupeKeys != nil {
+			seen[listItemKey(object, dedupeKeys)] = true
+		}
+	}
+	for i, item := range lower {
+		if object, ok := item.(map[string]interface{}); ok && dedupeKeys != nil && seen[listItemKey(object, dedupeKeys)] {
+			continue
+		}
+		origins[fmt.Sprintf("%s[%d]", path, len(result))] = lowerOrigins[i]
+		result = append(result, item)
+	}
+	return result
+}
+
+// listItemKey 返回数组条目中用于去重的字段的值
+func listItemKey(object map[string]interface{}, keys []string) string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = fmt.Sprint(object[key])
+	}
+	return strings.Join(values, "\x00")
+}
+
+// clearOrigins 删除 path 及其子字段的来源
+func clearOrigins(origins map[string]string, path string) {
+	for key := range origins {
+		if key == path || strings.HasPrefix(key, path+".") || strings.HasPrefix(key, path+"[") {
+			delete(origins, key)
+		}
+	}
+}
+
+// originOf 返回字段或其最近的上级字段的来源
+func originOf(origins map[string]string, field string) string {
+	for field != "" {
+		if origin, ok := origins[field]; ok {
+			return origin
+		}
+		i := strings.LastIndexAny(field, ".[")
+		if i < 0 {
+			break
+		}
+		field = field[:i]
+	}
+	return ""
+}
+
+// userLayerDiff 计算保存 config 时用户配置文件的内容：只保留与下层不同的值
+// 环境变量覆盖的字段保留用户原来的设置，不会被写入文件
+func userLayerDiff(config *Config, layers []*configLayer) (map[string]interface{}, error) {
+	var lower []*configLayer
+	var user *configLayer
+	var envKeys []string
+	for _, layer := range layers {
+		switch layer.Name {
+		case LayerUser:
+			user = layer
+		case LayerEnv:
+			for _, v := range configEnvVars {
+				if v.name == layer.Source {
+					envKeys = append(envKeys, v.key)
+				}
+			}
+		default:
+			lower = append(lower, layer)
+		}
+	}
+
+	full, err := configToMap(config)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"version", "include", "$schema"} {
+		delete(full, key)
+	}
+	base, _ := mergeLayers(lower)
+	diff := diffMaps(full, base, "")
+
+	previous := map[string]interface{}{}
+	if user != nil {
+		previous = user.raw
+	}
+	for _, key := range envKeys {
+		if value, ok := getConfigKey(previous, key); ok {
+			setConfigKey(diff, key, value)
+		} else {
+			deleteConfigKey(diff, key)
+		}
+	}
+	for _, key := range []string{"include", "$schema"} {
+		if value, ok := previous[key]; ok {
+			diff[key] = value
+		}
+	}
+	diff["version"] = CurrentConfigVersion
+	return diff, nil
+}
+
+// diffMaps 返回 full 中与 base 不同的部分，base 中有而 full 中没有的字段记为 null
+func diffMaps(full, base map[string]interface{}, prefix string) map[string]interface{} {
+	out := map[string]interface{}{}
+	for key, value := range full {
+		path := joinField(prefix, key)
+		baseValue, ok := base[key]
+		if object, isObject := value.(map[string]interface{}); isObject {
+			if baseObject, isBaseObject := baseValue.(map[string]interface{}); isBaseObject {
+				if sub := diffMaps(object, baseObject, path); len(sub) > 0 {
+					out[key] = sub
+				}
+				continue
+			}
+		}
+		if _, merged := mergedLists[path]; merged {
+			if extra := listDifference(value, baseValue); len(extra) > 0 {
+				out[key] = extra
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(value, baseValue) {
+			out[key] = value
+		}
+	}
+	for key := range base {
+		if _, ok := full[key]; !ok {
+			out[key] = nil
+		}
+	}
+	return out
+}
+
+// listDifference 返回 list 中不在 base 里的条目
+func listDifference(list, base interface{}) []interface{} {
+	items, _ := list.([]interface{})
+	baseItems, _ := base.([]interface{})
+	var out []interface{}
+	for _, item := range items {
+		found := false
+		for _, baseItem := range baseItems {
+			if reflect.DeepEqual(item, baseItem) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// deleteConfigKey 按以点分隔的路径删除字段
+func deleteConfigKey(config map[string]interface{}, key string) {
+	parent, name := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		parent, name = key[:i], key[i+1:]
+	}
+	node := interface{}(config)
+	if parent != "" {
+		node, _ = getConfigKey(config, parent)
+	}
+	if object, ok := node.(map[string]interface{}); ok {
+		delete(object, name)
+	}
+}
+
+// withUserLayer 返回用 user 替换用户配置层后的配置层
+func withUserLayer(layers []*configLayer, user map[string]interface{}) []*configLayer {
+	layer := &configLayer{Name: LayerUser, Source: ConfigPath(), raw: user, version: CurrentConfigVersion}
+	var out []*configLayer
+	inserted := false
+	for _, l := range layers {
+		if l.Name == LayerUser {
+			continue
+		}
+		if l.Name == LayerEnv && !inserted {
+			out, inserted = append(out, layer), true
+		}
+		out = append(out, l)
+	}
+	if !inserted {
+		out = append(out, layer)
+	}
+	return out
+}
+
+// writeUserConfig 写入用户配置文件
+func writeUserConfig(raw map[string]interface{}) error {
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(ConfigPath(), append(data, '\n'))
+}
+
+// cloneMap 深拷贝 JSON 对象
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(m)
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	if out == nil {
+		out = map[string]interface{}{}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 30 * time.Second
+
+	// cancelGracePeriod 是取消子进程后等待其自行退出的时间，超过后强制结束
+	cancelGracePeriod = 5 * time.Second
+)
+
+// jobsCtx 在服务停止、等待进行中的请求超时后取消
+// git 和 hook 子进程都在该 context 下运行，取消时先收到 SIGTERM，git clone 会删除未完成的目录
+var jobsCtx, cancelJobs = context.WithCancel(context.Background())
+
+// backgroundJobs 记录响应返回后仍在运行的任务（后台 hook），停止服务时一并等待
+var backgroundJobs sync.WaitGroup
+
+// checkStopping 在服务停止、子进程已被取消时返回错误，避免退出前仍启动 IDE
+func checkStopping() error {
+	if jobsCtx.Err() != nil {
+		return newServiceError(ErrCodeServiceStopping, "the service is shutting down", nil)
+	}
+	return nil
+}
+
+// shutdownTimeout 返回停止服务时等待进行中的请求的时间
+func (c *Config) shutdownTimeout() time.Duration {
+	if d, err := time.ParseDuration(c.ShutdownTimeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultShutdownTimeout
+}
+
+// runServer 在 listeners 上处理请求，收到 SIGINT 或 SIGTERM 后优雅退出：
+// 不再接受新连接，等待进行中的请求和后台任务完成；超过 shutdownTimeout 后取消 git 和 hook 子进程，
+// 再等待 cancelGracePeriod 让请求返回错误响应，最后关闭所有连接
+func runServer(srv *http.Server, listeners []net.Listener, live *liveService) error {
+	serveErrs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) { serveErrs <- srv.Serve(l) }(l)
+	}
+	sdNotify("READY=1\nSTATUS=Serving requests")
+	stopWatchdog := startWatchdog(live)
+	defer stopWatchdog()
+
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serveErrs:
+		srv.Close()
+		return err
+	case <-signals.Done():
+	}
+	// 再次收到信号时按默认行为立即退出
+	stop()
+
+	timeout := live.get().config.shutdownTimeout()
+	slog.Info("Shutting down, waiting for in-flight requests", "timeout", timeout)
+	sdNotify("STOPPING=1\nSTATUS=Waiting for in-flight requests")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	if err == nil {
+		err = waitBackgroundJobs(ctx)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("Shutdown timed out, canceling running git commands and hooks")
+		sdNotify("STATUS=Canceling running git commands and hooks")
+		cancelJobs()
+		// 子进程最多 cancelGracePeriod 后退出，多等一秒让请求写出错误响应
+		grace, cancel := context.WithTimeout(context.Background(), cancelGracePeriod+time.Second)
+		defer cancel()
+		if err := srv.Shutdown(grace); err != nil {
+			srv.Close()
+		}
+		waitBackgroundJobs(grace)
+	}
+	cancelJobs()
+	slog.Info("Service stopped")
+	return nil
+}
+
+// waitBackgroundJobs 等待后台任务完成，ctx 结束时返回其错误
+func waitBackgroundJobs(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		backgroundJobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
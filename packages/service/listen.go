@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// socketFileName 是默认 Unix socket 的文件名，放在 $XDG_RUNTIME_DIR 下
+	socketFileName = "github-browser.sock"
+
+	// maxSocketPathLen 是 Unix socket 路径的最大长度（macOS 的 sun_path 为 104 字节）
+	maxSocketPathLen = 103
+)
+
+// ListenConfig 配置服务监听 TCP 端口、Unix socket 或两者
+type ListenConfig struct {
+	TCP    *bool  `json:"tcp,omitempty"`    // 监听 TCP 端口 port，默认 true；浏览器扩展不通过 native messaging 访问时需要
+	Unix   bool   `json:"unix,omitempty"`   // 监听 Unix socket，只有当前用户可以连接
+	Socket string `json:"socket,omitempty"` // Unix socket 路径，默认 $XDG_RUNTIME_DIR/github-browser.sock
+}
+
+// tcp 判断是否监听 TCP 端口
+func (lc *ListenConfig) tcp() bool {
+	return lc == nil || lc.TCP == nil || *lc.TCP
+}
+
+// unix 判断是否监听 Unix socket
+func (lc *ListenConfig) unix() bool {
+	return lc != nil && lc.Unix
+}
+
+// socketPath 返回 Unix socket 路径
+// 默认放在 $XDG_RUNTIME_DIR 下，install.sh 为 systemd 服务设置了同一个 XDG_RUNTIME_DIR，与用户会话中的 CLI 一致；
+// 没有 XDG_RUNTIME_DIR 时（如 macOS）使用 ~/.github-browser/github-browser.sock
+func (lc *ListenConfig) socketPath() string {
+	if lc != nil && lc.Socket != "" {
+		return expandPath(lc.Socket)
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, socketFileName)
+	}
+	return filepath.Join(filepath.Dir(ConfigPath()), socketFileName)
+}
+
+// validate 检查监听配置是否有效
+func (lc *ListenConfig) validate() error {
+	if !lc.tcp() && !lc.unix() {
+		return fmt.Errorf("at least one of tcp and unix must be enabled")
+	}
+	if lc.Socket != "" {
+		if err := validateLocalPath(lc.Socket); err != nil {
+			return fmt.Errorf("socket: %v", err)
+		}
+		if len(expandPath(lc.Socket)) > maxSocketPathLen {
+			return fmt.Errorf("socket: path is longer than %d bytes", maxSocketPathLen)
+		}
+	}
+	return nil
+}
+
+// listen 返回服务的监听套接字
+// 由 systemd socket activation 启动时使用传入的套接字，其余按配置监听 TCP 端口和 Unix socket；
+// 配置关闭了 tcp 时不使用 systemd 传入的 TCP 套接字
+func listen(config *Config) ([]net.Listener, error) {
+	activated, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	lc := config.Listen
+	var listeners []net.Listener
+	var activatedTCP, activatedUnix bool
+	for _, l := range activated {
+		switch l.Addr().Network() {
+		case "unix":
+			activatedUnix = true
+		default:
+			if !lc.tcp() {
+				slog.Warn("Ignoring socket-activated TCP listener because listen.tcp is false; remove ListenStream from the systemd socket unit", "addr", l.Addr().String())
+				l.Close()
+				continue
+			}
+			activatedTCP = true
+		}
+		listeners = append(listeners, l)
+	}
+
+	if lc.tcp() && !activatedTCP {
+		port := config.Port
+		if port == 0 {
+			port = DefaultPort
+		}
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if lc.unix() && !activatedUnix {
+		l, err := listenUnix(lc.socketPath())
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// listenUnix 在 path 上监听 Unix socket，权限为 0600，只有当前用户可以连接
+// socket 在 umask 077 下创建，不存在先以默认权限创建、再 chmod 的时间窗口；
+// 上次异常退出留下的 socket 文件会被删除，已有服务在监听时返回错误
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another service is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var l net.Listener
+	err := withUmask(0077, func() (err error) {
+		l, err = net.Listen("unix", path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
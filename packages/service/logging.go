@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultLogFile 是相对于 HOME 的默认日志文件
+	DefaultLogFile = ".github-browser/logs/service.log"
+
+	defaultLogMaxSizeMB = 10
+	defaultLogMaxFiles  = 5
+
+	// LogFileOff 作为 log.file 时不写日志文件
+	LogFileOff = "off"
+
+	// requestIDHeader 是请求和响应中携带请求 ID 的头
+	requestIDHeader = "X-Request-ID"
+	// requestIDKey 是请求 ID 在 gin.Context 中的键
+	requestIDKey = "requestID"
+
+	defaultLogQueryLimit = 200
+	maxLogQueryLimit     = 5000
+)
+
+// LogConfig 定义服务日志的级别、格式和日志文件
+type LogConfig struct {
+	Level     string `json:"level,omitempty"`     // debug、info、warn 或 error，默认 info；debug 时记录每条 git 命令及耗时
+	Format    string `json:"format,omitempty"`    // 控制台日志格式：text 或 json，默认 text；日志文件总是 JSON
+	File      string `json:"file,omitempty"`      // 日志文件，默认 ~/.github-browser/logs/service.log，"off" 表示不写文件
+	MaxSizeMB int    `json:"maxSizeMB,omitempty"` // 日志文件超过该大小（MB）时轮转，默认 10
+	MaxFiles  int    `json:"maxFiles,omitempty"`  // 保留的旧日志文件数，默认 5
+}
+
+// logLevel 是当前的日志级别，配置重新加载时更新
+var logLevel = new(slog.LevelVar)
+
+// level 返回配置的日志级别
+func (lc *LogConfig) level() slog.Level {
+	var level slog.Level
+	if lc != nil && lc.Level != "" {
+		level.UnmarshalText([]byte(lc.Level))
+	}
+	return level
+}
+
+// filePath 返回日志文件路径，不写文件时返回空字符串
+func (lc *LogConfig) filePath() string {
+	switch {
+	case lc == nil || lc.File == "":
+		return filepath.Join(os.Getenv("HOME"), DefaultLogFile)
+	case lc.File == LogFileOff:
+		return ""
+	}
+	return expandPath(lc.File)
+}
+
+// validate 检查日志配置是否有效
+func (lc LogConfig) validate() error {
+	if lc.Level != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(lc.Level)); err != nil {
+			return fmt.Errorf("level: must be debug, info, warn or error")
+		}
+	}
+	switch lc.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("format: must be text or json")
+	}
+	if lc.File != LogFileOff {
+		if err := validateLocalPath(lc.File); err != nil {
+			return fmt.Errorf("file: %v", err)
+		}
+	}
+	if lc.MaxSizeMB < 0 {
+		return fmt.Errorf("maxSizeMB: must not be negative")
+	}
+	if lc.MaxFiles < 0 {
+		return fmt.Errorf("maxFiles: must not be negative")
+	}
+	return nil
+}
+
+// setupLogging 按配置设置默认的 slog logger：控制台输出到 stderr，同时以 JSON 写入轮转的日志文件
+// 标准库 log 包的输出也会转到该 logger
+func setupLogging(lc *LogConfig) (io.Closer, error) {
+	logLevel.Set(lc.level())
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var console slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if lc != nil && lc.Format == "json" {
+		console = slog.NewJSONHandler(os.Stderr, opts)
+	}
+
+	path := lc.filePath()
+	if path == "" {
+		slog.SetDefault(slog.New(console))
+		return io.NopCloser(nil), nil
+	}
+	maxSize, maxFiles := defaultLogMaxSizeMB, defaultLogMaxFiles
+	if lc != nil && lc.MaxSizeMB > 0 {
+		maxSize = lc.MaxSizeMB
+	}
+	if lc != nil && lc.MaxFiles > 0 {
+		maxFiles = lc.MaxFiles
+	}
+	file, err := openRotatingFile(path, int64(maxSize)<<20, maxFiles)
+	if err != nil {
+		slog.SetDefault(slog.New(console))
+		return io.NopCloser(nil), err
+	}
+	slog.SetDefault(slog.New(teeHandler{console, slog.NewJSONHandler(file, opts)}))
+	return file, nil
+}
+
+// teeHandler 把日志同时交给多个 handler
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// rotatingFile 是超过大小后轮转的日志文件：service.log → service.log.1 → … → service.log.<maxFiles>
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	rf := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	return rf, rf.open()
+}
+
+func (rf *rotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file, rf.size = file, info.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件，把旧文件依次改名，删除超出数量的文件
+func (rf *rotatingFile) rotate() error {
+	rf.file.Close()
+	os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.maxFiles))
+	for i := rf.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+	}
+	if err := os.Rename(rf.path, rf.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return rf.open()
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.file.Close()
+}
+
+// requestIDPattern 限制客户端传入的请求 ID，避免把任意内容写入日志
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// newRequestID 生成随机的请求 ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID 返回当前请求的 ID
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// requestLogger 为每个请求分配 ID（优先使用客户端传入的 X-Request-ID），在响应头中返回并记录访问日志
+// /health 和 /metrics 会被频繁轮询，只在 debug 级别记录
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		switch {
+		case c.Request.Method == "OPTIONS" || c.Request.URL.Path == "/health" || c.Request.URL.Path == "/metrics":
+			level = slog.LevelDebug
+		case c.Writer.Status() >= 500:
+			level = slog.LevelError
+		}
+		slog.Log(c.Request.Context(), level, "HTTP request",
+			"request_id", id,
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	}
+}
+
+// forRequest 返回处理该请求使用的 Service，日志和 git 命令都带上请求 ID
+func (s *Service) forRequest(c *gin.Context) *Service {
+	id := requestID(c)
+	if id == "" {
+		return s
+	}
+	scoped := *s
+	scoped.requestID = id
+	scoped.log = s.log.With("request_id", id)
+	scoped.gitClient = s.gitClient.WithLogger(scoped.log)
+	return &scoped
+}
+
+// LogEntry 是日志文件中的一条记录
+type LogEntry map[string]interface{}
+
+// readLogEntries 按时间顺序读取日志文件及轮转的旧文件，返回匹配 requestID 的最后 limit 条记录
+// requestID 为空时返回所有记录中的最后 limit 条
+func readLogEntries(path, requestID string, minLevel slog.Level, limit int) ([]LogEntry, error) {
+	files, _ := filepath.Glob(path + ".*")
+	rotated := map[int]string{}
+	oldest := 0
+	for _, f := range files {
+		if n, err := strconv.Atoi(strings.TrimPrefix(f, path+".")); err == nil && n > 0 {
+			rotated[n] = f
+			if n > oldest {
+				oldest = n
+			}
+		}
+	}
+	ordered := make([]string, 0, len(rotated)+1)
+	for n := oldest; n >= 1; n-- {
+		if f, ok := rotated[n]; ok {
+			ordered = append(ordered, f)
+		}
+	}
+	ordered = append(ordered, path)
+
+	entries := []LogEntry{}
+	for _, name := range ordered {
+		file, err := os.Open(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			var entry LogEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+				continue
+			}
+			if requestID != "" && entry["request_id"] != requestID {
+				continue
+			}
+			if levelName, _ := entry["level"].(string); levelName != "" {
+				var level slog.Level
+				if level.UnmarshalText([]byte(levelName)) == nil && level < minLevel {
+					continue
+				}
+			}
+			entries = append(entries, entry)
+			if len(entries) > limit {
+				entries = entries[1:]
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// LogsResponse 是 GET /logs 的响应
+type LogsResponse struct {
+	Status  string     `json:"status"`
+	Request string     `json:"request"` // 查询的请求 ID，为空表示所有请求
+	File    string     `json:"file"`    // 日志文件路径
+	Entries []LogEntry `json:"entries"`
+	Count   int        `json:"count"`
+}
+
+// handleLogs 返回日志文件中的记录，request 参数指定请求 ID 时只返回该请求的日志
+// 用于在报告问题时附上一次打开的完整诊断信息
+func (s *Service) handleLogs(c *gin.Context) {
+	path := s.config.Log.filePath()
+	if path == "" {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, "File logging is disabled (log.file is \"off\")", nil))
+		return
+	}
+
+	limit := defaultLogQueryLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, newServiceError(ErrCodeInvalidRequest, "Invalid limit: "+v, err))
+			return
+		}
+		limit = min(n, maxLogQueryLimit)
+	}
+	var level slog.Level = slog.LevelDebug
+	if v := c.Query("level"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			respondError(c, newServiceError(ErrCodeInvalidRequest, "Invalid level: "+v, err))
+			return
+		}
+	}
+
+	id := c.Query("request")
+	entries, err := readLogEntries(path, id, level, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(200, LogsResponse{
+		Status:  "ok",
+		Request: id,
+		File:    path,
+		Entries: entries,
+		Count:   len(entries),
+	})
+}
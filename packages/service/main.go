@@ -1,11 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,8 +20,12 @@ const (
 type Service struct {
 	config    *Config
 	cacheDir  string
-	gitClient *GitClient
+	gitClient GitClient
 	ghClient  *GitHubClient
+	paths     *pathResolver
+	log       *slog.Logger // 处理请求时带有 request_id
+	requestID string
+	live      *liveService // 配置更新后替换为新的 Service
 }
 
 type OpenRequest struct {
@@ -28,129 +33,366 @@ type OpenRequest struct {
 	IDE      string `json:"ide"`
 	FilePath string `json:"filePath"`
 	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Target   string `json:"target"` // local、devcontainer 或 ssh，为空时按配置自动选择
 }
 
 type OpenResponse struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
-	Path    string `json:"path,omitempty"`
+	Status       string           `json:"status"`
+	Message      string           `json:"message"`
+	Code         ErrorCode        `json:"code,omitempty"`    // 错误码，仅在 status 为 error 时返回
+	Hint         string           `json:"hint,omitempty"`    // 修复建议
+	Details      string           `json:"details,omitempty"` // 脱敏后的原始错误输出
+	Path         string           `json:"path,omitempty"`
+	Skipped      []string         `json:"skipped,omitempty"`      // 被跳过的准备步骤及原因
+	IDE          string           `json:"ide,omitempty"`          // 实际使用的 IDE
+	FallbackFrom string           `json:"fallbackFrom,omitempty"` // 未安装而被替换的 IDE
+	Launch       *LaunchResult    `json:"launch,omitempty"`       // 复用了已运行的实例还是启动了新进程
+	Hooks        []HookResult     `json:"hooks,omitempty"`        // 执行的初始化 hook
+	Target       string           `json:"target,omitempty"`       // 实际使用的目标模式：local、devcontainer 或 ssh
+	Timings      map[string]int64 `json:"timings,omitempty"`      // 各阶段耗时（毫秒），如 clone、fetch、checkout、launch
+	RequestID    string           `json:"requestId,omitempty"`    // 请求 ID，可用于 GET /logs?request=<id> 查询该请求的日志
 }
 
 func main() {
-	// 初始化配置
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// loadConfigOrDefault 加载配置文件，失败时使用默认配置
+func loadConfigOrDefault() *Config {
 	config, err := LoadConfig()
 	if err != nil {
-		log.Printf("Warning: Failed to load config: %v, using defaults", err)
+		slog.Warn("Failed to load config, using defaults", "error", err)
 		config = DefaultConfig()
 	}
+	return config
+}
+
+// newService 根据配置创建服务，配置可以在运行中更新
+func newService(config *Config) (*Service, error) {
+	service, err := buildService(config)
+	if err != nil {
+		return nil, err
+	}
+	service.live = newLiveService(service)
+	return service, nil
+}
 
-	// 创建默认缓存目录
+// buildService 根据配置创建 Service，并确保缓存目录存在
+func buildService(config *Config) (*Service, error) {
 	cacheDir := config.CacheDir
 	if cacheDir == "" {
 		cacheDir = filepath.Join(os.Getenv("HOME"), DefaultCacheDir)
 	}
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		log.Fatalf("Failed to create cache directory: %v", err)
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	// 初始化服务
-	service := &Service{
+	gitClient, err := NewGitClient(config.GitBackend, cacheDir, config.GitHubToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create git client: %w", err)
+	}
+
+	paths, err := newPathResolver(config.PathMappings, cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
 		config:    config,
 		cacheDir:  cacheDir,
-		gitClient: NewGitClient(cacheDir),
+		gitClient: gitClient,
 		ghClient:  NewGitHubClient(config.GitHubToken),
-	}
+		paths:     paths,
+		log:       slog.Default(),
+	}, nil
+}
 
-	// 设置 Gin
+// router 创建注册了所有 API 的 Gin 引擎，每个请求使用当时生效的配置
+func (s *Service) router() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+	r.Use(requestLogger(), gin.Recovery())
 
-	// CORS
+	// CORS：只对允许的来源（浏览器扩展）返回跨域头，其他网页无法读取响应
+	allowed := func() []string { return s.live.get().config.AllowedOrigins }
 	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if origin := c.GetHeader("Origin"); origin != "" && allowedOrigin(origin, allowed()) {
+			header := c.Writer.Header()
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Methods", "POST, GET, PUT, PATCH, DELETE, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", "Content-Type, "+requestIDHeader)
+			header.Set("Access-Control-Expose-Headers", requestIDHeader)
+			header.Add("Vary", "Origin")
+		}
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
 		}
 		c.Next()
 	})
+	r.Use(rejectCrossSite(allowed))
 
-	// 路由
-	r.GET("/health", service.handleHealth)
-	r.POST("/open", service.handleOpen)
-	r.GET("/cache", service.handleListCache)
-	r.DELETE("/cache/:repo", service.handleDeleteCache)
-	r.GET("/config", service.handleGetConfig)
-	r.PUT("/config", service.handleUpdateConfig)
+	// 路由，接口定义见 apiRoutes
+	h := s.live.handle
+	for _, route := range apiRoutes {
+		if route.handler != nil {
+			r.Handle(route.method, route.path, h(route.handler))
+		}
+	}
+	r.GET("/metrics", metricsHandler(s.live))
+	// handleOpenAPI 根据 apiRoutes 生成文档，不能出现在 apiRoutes 中
+	r.GET("/openapi.json", h((*Service).handleOpenAPI))
+
+	return r
+}
+
+// extensionOriginPrefixes 是浏览器扩展页面发出请求时的 Origin 前缀
+var extensionOriginPrefixes = []string{"chrome-extension://", "moz-extension://", "safari-web-extension://"}
+
+// allowedOrigin 判断是否接受来自 origin 的请求
+// 命令行和 IDE 插件的请求不带 Origin，浏览器扩展的请求带扩展页面的 Origin；
+// 配置了 allowedOrigins 时只接受其中的来源
+func allowedOrigin(origin string, allowed []string) bool {
+	if origin == "" {
+		return true
+	}
+	if len(allowed) > 0 {
+		for _, o := range allowed {
+			if o == origin {
+				return true
+			}
+		}
+		return false
+	}
+	for _, prefix := range extensionOriginPrefixes {
+		if strings.HasPrefix(origin, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectCrossSite 拒绝网页发起的修改请求，防止任意网站通过浏览器调用本地服务
+// 带请求体的请求必须是 JSON，网页无需 CORS 预检就能发送的 text/plain 和表单请求不会被处理
+func rejectCrossSite(allowed func() []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if origin := c.GetHeader("Origin"); !allowedOrigin(origin, allowed()) {
+			respondError(c, newServiceError(ErrCodeForbidden, fmt.Sprintf("requests from %s are not allowed", origin), nil))
+			c.Abort()
+			return
+		}
+		if c.Request.ContentLength != 0 && c.ContentType() != "application/json" {
+			respondError(c, newServiceError(ErrCodeInvalidRequest, "Content-Type must be application/json", nil))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// serve 启动 HTTP 服务，收到 SIGINT 或 SIGTERM 后优雅退出
+func serve() error {
+	takeSystemdEnv()
+
+	// 初始化配置
+	config := loadConfigOrDefault()
+
+	logFile, err := setupLogging(config.Log)
+	if err != nil {
+		slog.Warn("Failed to open log file, logging to stderr only", "error", err)
+	}
+	defer logFile.Close()
+
+	// 初始化服务
+	service, err := newService(config)
+	if err != nil {
+		return err
+	}
 
 	// 启动服务
 	port := config.Port
 	if port == 0 {
 		port = DefaultPort
 	}
-
-	log.Printf("🚀 GitHub Browser service started on http://localhost:%d", port)
-	log.Printf("📁 Cache directory: %s", cacheDir)
-	log.Printf("💻 Default IDE: %s", config.DefaultIDE)
-
-	if err := r.Run(fmt.Sprintf(":%d", port)); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	listeners, err := listen(config)
+	if err != nil {
+		return err
+	}
+	addrs := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		addrs = append(addrs, l.Addr().String())
 	}
-}
 
-func (s *Service) handleHealth(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"status":  "ok",
-		"version": "1.0.0",
-		"uptime":  time.Since(time.Now()).String(),
-	})
+	url := fmt.Sprintf("http://localhost:%d", port)
+	if !config.Listen.tcp() {
+		url = unixSocketPrefix + config.Listen.socketPath()
+	}
+	slog.Info("GitHub Browser service started",
+		"url", url,
+		"listen", addrs,
+		"version", version,
+		"cache_dir", service.cacheDir,
+		"default_ide", config.DefaultIDE,
+		"log_file", config.Log.filePath(),
+	)
+
+	// 配置文件修改后自动重新加载
+	go service.live.watch()
+
+	srv := &http.Server{
+		Handler:           service.router(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return runServer(srv, listeners, service.live)
 }
 
 func (s *Service) handleOpen(c *gin.Context) {
 	var req OpenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, OpenResponse{
-			Status:  "error",
-			Message: fmt.Sprintf("Invalid request: %v", err),
-		})
+		respondError(c, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid request: %v", err), err))
 		return
 	}
+	s.open(c, &req)
+}
 
-	log.Printf("📥 Received request: %s", req.URL)
+// open 打开请求的仓库或 PR 并写入响应，结果记录在打开历史中
+func (s *Service) open(c *gin.Context, req *OpenRequest) {
+	s.log.Info("Received open request", "url", req.URL)
+
+	start := time.Now()
+	var urlType URLType
+	var ide string
+	entry := newHistoryEntry(c, req.URL, nil)
+	defer func() {
+		observeOpen(c, urlType, ide, s.config.CustomIDEs, start)
+		if entry.IDE = ide; entry.IDE == "" {
+			entry.IDE = req.IDE
+		}
+		entry.finish(c, start)
+		s.recordHistory(entry)
+	}()
 
 	// 解析 URL
 	info, err := ParseGitHubURL(req.URL)
 	if err != nil {
-		c.JSON(400, OpenResponse{
-			Status:  "error",
-			Message: fmt.Sprintf("Invalid GitHub URL: %v", err),
-		})
+		respondError(c, fmt.Errorf("Invalid GitHub URL: %w", err))
 		return
 	}
+	urlType = info.Type
+	entry.setURLInfo(info)
+	entry.setRequest(req)
 
-	log.Printf("📦 Parsed: owner=%s, repo=%s, type=%s", info.Owner, info.Repo, info.Type)
+	s.log.Debug("Parsed URL", "owner", info.Owner, "repo", info.Repo, "type", info.Type)
 
-	// 处理不同类型
-	var repoPath string
-	switch info.Type {
-	case URLTypeRepo:
-		repoPath, err = s.handleRepository(info)
-	case URLTypePR:
-		repoPath, err = s.handlePullRequest(info)
-	default:
-		err = fmt.Errorf("unsupported URL type: %s", info.Type)
+	mode, err := s.requestedTargetMode(req.Target, info)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	host, err := s.prepareHost(mode, info)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 
+	timer := newStageTimer()
+	repoPath, stages, err := s.prepareRepository(info, host, timer)
 	if err != nil {
-		c.JSON(500, OpenResponse{
-			Status:  "error",
-			Message: err.Error(),
+		respondError(c, err)
+		return
+	}
+
+	target, choice := s.resolveTarget(req, info, repoPath)
+	target.Mode = mode
+	ide = choice.IDE
+	entry.Path = repoPath
+	if err := s.applyDevContainer(req.Target, &target, ide); err != nil {
+		respondError(c, err)
+		return
+	}
+	entry.Target = target.Mode
+
+	var skipped []string
+	var hooks []HookResult
+	if mode == TargetSSH {
+		skipped = append(skipped, "submodules, LFS and hooks: not run on remote host")
+	} else {
+		// 初始化子模块、拉取 LFS 文件
+		skipped = s.prepareWorkingTree(info, repoPath, target.Path, timer)
+
+		// 执行初始化 hook
+		timer.time(StageHooks, func() error {
+			hooks = s.runHooks(info, repoPath, append(stages, HookBeforeLaunch))
+			return nil
 		})
+	}
+
+	// 打开 IDE
+	if err := checkStopping(); err != nil {
+		respondError(c, err)
 		return
 	}
+	if choice.Rule != nil {
+		s.log.Info("IDE rule matched", "rule", ruleLabel(choice.Rule))
+	}
+	if choice.FallbackFrom != "" {
+		s.log.Warn("IDE is not installed, falling back", "ide", choice.FallbackFrom, "fallback", ide)
+	}
+	s.log.Info("Opening in IDE", "ide", ide, "target", target.Mode, "path", target.Path, "line", target.Line)
+	var launch *LaunchResult
+	err = timer.time(StageLaunch, func() error {
+		launch, err = OpenInIDE(ide, target, s.config)
+		return err
+	})
+	if err != nil {
+		respondError(c, fmt.Errorf("Failed to open IDE: %w", err))
+		return
+	}
+
+	c.JSON(200, OpenResponse{
+		Status:       "ok",
+		Message:      "Opened successfully",
+		Path:         repoPath,
+		Skipped:      skipped,
+		IDE:          ide,
+		FallbackFrom: choice.FallbackFrom,
+		Launch:       launch,
+		Hooks:        hooks,
+		Target:       target.Mode,
+		Timings:      timer.milliseconds(),
+		RequestID:    requestID(c),
+	})
+}
+
+// resolveTarget 确定要打开的位置和 IDE，请求中的字段优先于 URL 中解析出的值
+// 请求未指定 IDE 时按 ideRules 选择，都不匹配时使用 defaultIDE；IDE 未安装时按 idePreference 回退
+func (s *Service) resolveTarget(req *OpenRequest, info *GitHubURLInfo, repoPath string) (OpenTarget, ideChoice) {
+	target := openTarget(req, info, repoPath)
+
+	// 确定 IDE
+	choice := ideChoice{IDE: req.IDE}
+	if choice.IDE == "" {
+		choice.Rule, choice.Language = s.matchIDERule(info, target)
+		if choice.Rule != nil {
+			choice.IDE = choice.Rule.IDE
+		} else {
+			choice.IDE = s.config.DefaultIDE
+		}
+	}
+
+	choice.IDE, choice.FallbackFrom = chooseIDE(choice.IDE, s.config.IDEPreference, s.config.CustomIDEs)
+
+	return target, choice
+}
 
+// openTarget 根据请求和 URL 确定要打开的文件和行号，请求中的值优先
+func openTarget(req *OpenRequest, info *GitHubURLInfo, repoPath string) OpenTarget {
 	// 确定要打开的文件路径
 	var targetPath string
 	if req.FilePath != "" {
@@ -167,153 +409,245 @@ func (s *Service) handleOpen(c *gin.Context) {
 		line = info.Line
 	}
 
-	// 确定 IDE
-	ide := req.IDE
-	if ide == "" {
-		ide = s.config.DefaultIDE
-	}
-
-	// 打开 IDE
-	log.Printf("🚀 Opening in %s: %s (line: %d)", ide, targetPath, line)
-	if err := OpenInIDE(ide, targetPath, line); err != nil {
-		c.JSON(500, OpenResponse{
-			Status:  "error",
-			Message: fmt.Sprintf("Failed to open IDE: %v", err),
-		})
-		return
-	}
+	return OpenTarget{RepoPath: repoPath, Path: targetPath, Line: line, Column: req.Column}
+}
 
-	c.JSON(200, OpenResponse{
-		Status:  "ok",
-		Message: "Opened successfully",
-		Path:    repoPath,
+// respondError 将错误转换为带错误码的 OpenResponse 并设置对应的 HTTP 状态码
+func respondError(c *gin.Context, err error) {
+	se := asServiceError(err)
+	c.Set(errorCodeKey, string(se.Code))
+	c.Set(errorMessageKey, se.Message)
+	c.JSON(se.Code.HTTPStatus(), OpenResponse{
+		Status:    "error",
+		Message:   se.Message,
+		Code:      se.Code,
+		Hint:      se.Hint,
+		Details:   se.Details,
+		RequestID: requestID(c),
 	})
 }
 
-func (s *Service) handleRepository(info *GitHubURLInfo) (string, error) {
-	repoPath := s.config.GetRepoPath(info.Owner, info.Repo)
+// prepareRepository 按 URL 类型克隆或更新仓库并切换到对应的分支
+// 返回本地路径和经过的 hook 阶段（after-clone、after-checkout），各步骤的耗时记录在 timer 中
+func (s *Service) prepareRepository(info *GitHubURLInfo, host *repoHost, timer *stageTimer) (string, []string, error) {
+	switch info.Type {
+	case URLTypeRepo:
+		return s.handleRepository(info, host, timer)
+	case URLTypePR:
+		return s.handlePullRequest(info, host, timer)
+	default:
+		return "", nil, fmt.Errorf("unsupported URL type: %s", info.Type)
+	}
+}
+
+func (s *Service) handleRepository(info *GitHubURLInfo, host *repoHost, timer *stageTimer) (string, []string, error) {
+	repoPath := host.repoPath
+	strategy := s.gitStrategy(info)
+	var stages []string
 
 	// 克隆或更新
-	if _, err := os.Stat(repoPath); err == nil {
-		log.Printf("📦 Repository exists, updating...")
-		if err := s.gitClient.Pull(repoPath); err != nil {
-			log.Printf("⚠️  Warning: git pull failed: %v", err)
+	if host.exists() {
+		s.log.Info("Repository exists, updating", "path", repoPath)
+		if err := timer.time(StagePull, func() error { return host.git.Pull(repoPath) }); err != nil {
+			s.log.Warn("git pull failed", "error", err)
+		}
+		// 映射改为完整历史后，加深之前的浅克隆
+		if strategy.Depth == DepthFull && info.Branch == "" && host.git.IsShallow(repoPath) {
+			if err := timer.time(StageFetch, func() error { return host.git.Unshallow(repoPath) }); err != nil {
+				s.log.Warn("git fetch --unshallow failed", "error", err)
+			}
 		}
 	} else {
-		log.Printf("📥 Cloning repository...")
+		s.log.Info("Cloning repository", "path", repoPath)
 		repoURL := fmt.Sprintf("https://github.com/%s/%s.git", info.Owner, info.Repo)
-		if err := s.gitClient.Clone(repoURL, repoPath); err != nil {
-			return "", fmt.Errorf("failed to clone: %v", err)
+		if err := timer.time(StageClone, func() error { return host.git.Clone(repoURL, repoPath, strategy) }); err != nil {
+			host.discardClone()
+			return "", nil, fmt.Errorf("failed to clone: %w", err)
 		}
+		stages = append(stages, HookAfterClone, HookAfterCheckout)
 	}
 
 	// 如果指定了分支或 tag，切换到该分支/tag
 	if info.Branch != "" {
-		log.Printf("🔀 Checking out branch/tag: %s", info.Branch)
+		s.log.Info("Checking out branch or tag", "ref", info.Branch)
 		// 先 fetch 确保有最新的远程分支
-		if err := s.gitClient.Fetch(repoPath); err != nil {
-			log.Printf("⚠️  Warning: git fetch failed: %v", err)
+		if err := timer.time(StageFetch, func() error { return host.git.Fetch(repoPath, strategy) }); err != nil {
+			s.log.Warn("git fetch failed", "error", err)
+		}
+		if err := timer.time(StageCheckout, func() error { return host.git.Checkout(repoPath, info.Branch, strategy) }); err != nil {
+			return "", nil, fmt.Errorf("failed to checkout %s: %w", info.Branch, err)
 		}
-		if err := s.gitClient.Checkout(repoPath, info.Branch); err != nil {
-			return "", fmt.Errorf("failed to checkout %s: %v", info.Branch, err)
+		if len(stages) == 0 {
+			stages = append(stages, HookAfterCheckout)
 		}
 	}
 
-	return repoPath, nil
+	return repoPath, stages, nil
 }
 
-func (s *Service) handlePullRequest(info *GitHubURLInfo) (string, error) {
-	repoPath := s.config.GetRepoPath(info.Owner, info.Repo)
+func (s *Service) handlePullRequest(info *GitHubURLInfo, host *repoHost, timer *stageTimer) (string, []string, error) {
+	repoPath := host.repoPath
+	strategy := s.gitStrategy(info)
+	var stages []string
 
 	// 克隆或更新主仓库
-	if _, err := os.Stat(repoPath); err == nil {
-		log.Printf("📦 Repository exists, fetching updates...")
-		if err := s.gitClient.Fetch(repoPath); err != nil {
-			log.Printf("⚠️  Warning: git fetch failed: %v", err)
+	if host.exists() {
+		s.log.Info("Repository exists, fetching updates", "path", repoPath)
+		if err := timer.time(StageFetch, func() error { return host.git.Fetch(repoPath, strategy) }); err != nil {
+			s.log.Warn("git fetch failed", "error", err)
 		}
 	} else {
-		log.Printf("📥 Cloning repository...")
+		s.log.Info("Cloning repository", "path", repoPath)
 		repoURL := fmt.Sprintf("https://github.com/%s/%s.git", info.Owner, info.Repo)
-		if err := s.gitClient.Clone(repoURL, repoPath); err != nil {
-			return "", fmt.Errorf("failed to clone: %v", err)
+		if err := timer.time(StageClone, func() error { return host.git.Clone(repoURL, repoPath, strategy) }); err != nil {
+			host.discardClone()
+			return "", nil, fmt.Errorf("failed to clone: %w", err)
 		}
+		stages = append(stages, HookAfterClone, HookAfterCheckout)
 	}
 
 	// 使用 git fetch 直接获取 PR 分支（无需 GitHub API）
 	// GitHub 支持 refs/pull/<PR_NUMBER>/head 格式
-	log.Printf("📥 Fetching PR #%d branch...", info.PRNumber)
+	s.log.Info("Fetching PR branch", "pr", info.PRNumber)
 	prBranchName := fmt.Sprintf("pr-%d", info.PRNumber)
-	if err := s.gitClient.FetchPR(repoPath, info.PRNumber, prBranchName); err != nil {
-		return "", fmt.Errorf("failed to fetch PR: %v", err)
+	if err := timer.time(StageFetch, func() error { return host.git.FetchPR(repoPath, info.PRNumber, prBranchName, strategy) }); err != nil {
+		return "", nil, fmt.Errorf("failed to fetch PR: %w", err)
 	}
 
-	log.Printf("🔀 Checking out PR branch: %s", prBranchName)
-	if err := s.gitClient.Checkout(repoPath, prBranchName); err != nil {
-		return "", fmt.Errorf("failed to checkout PR branch: %v", err)
+	s.log.Info("Checking out PR branch", "branch", prBranchName)
+	if err := timer.time(StageCheckout, func() error { return host.git.Checkout(repoPath, prBranchName, strategy) }); err != nil {
+		return "", nil, fmt.Errorf("failed to checkout PR branch: %w", err)
 	}
+	if len(stages) == 0 {
+		stages = append(stages, HookAfterCheckout)
+	}
+
+	return repoPath, stages, nil
+}
+
+// prepareWorkingTree 在 clone/checkout 之后按策略处理子模块和 LFS
+// 返回被跳过的步骤说明，失败不会中断打开流程
+func (s *Service) prepareWorkingTree(info *GitHubURLInfo, repoPath, targetPath string, timer *stageTimer) []string {
+	strategy := s.gitStrategy(info)
+	var skipped []string
+
+	if hasSubmodules(repoPath) {
+		if strategy.Submodules == SubmodulesOff {
+			skipped = append(skipped, "submodule update: disabled by config")
+		} else {
+			s.log.Info("Updating submodules")
+			if err := timer.time(StageSubmodules, func() error { return s.gitClient.UpdateSubmodules(repoPath, strategy) }); err != nil {
+				s.log.Warn("Preparing working tree failed", "error", err)
+				skipped = append(skipped, fmt.Sprintf("submodule update: %v", err))
+			}
+		}
+	}
+
+	if usesLFS(repoPath) {
+		include, _ := filepath.Rel(repoPath, targetPath)
+		switch {
+		case strategy.LFS == LFSOff:
+			skipped = append(skipped, "git lfs pull: disabled by config")
+		case !s.gitClient.HasLFS():
+			skipped = append(skipped, "git lfs pull: git-lfs is not available")
+		case strategy.LFS == LFSPath && (include == "." || include == ""):
+			skipped = append(skipped, `git lfs pull: no file path to limit to (set lfs to "all" to pull everything)`)
+		default:
+			if strategy.LFS == LFSAll {
+				include = ""
+			}
+			s.log.Info("Pulling LFS objects", "include", include)
+			if err := timer.time(StageLFS, func() error { return s.gitClient.LFSPull(repoPath, include) }); err != nil {
+				s.log.Warn("Preparing working tree failed", "error", err)
+				skipped = append(skipped, fmt.Sprintf("git lfs pull: %v", err))
+			}
+		}
+	}
+
+	return skipped
+}
+
+// IDEListResponse 是 GET /ides 的响应
+type IDEListResponse struct {
+	IDEs       []IDEInfo `json:"ides"`
+	DefaultIDE string    `json:"defaultIDE"`
+	Preference []string  `json:"preference"` // 配置中的 idePreference
+}
+
+func (s *Service) handleListIDEs(c *gin.Context) {
+	c.JSON(200, IDEListResponse{
+		IDEs:       DetectIDEs(s.config.CustomIDEs),
+		DefaultIDE: s.config.DefaultIDE,
+		Preference: s.config.IDEPreference,
+	})
+}
 
-	return repoPath, nil
+// CachedRepo 是 GET /cache 列出的一个仓库目录
+type CachedRepo struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Modified time.Time `json:"modified"` // 目录的修改时间
+}
+
+// CacheListResponse 是 GET /cache 的响应
+type CacheListResponse struct {
+	Repos []CachedRepo `json:"repos"`
+	Count int          `json:"count"`
 }
 
 func (s *Service) handleListCache(c *gin.Context) {
 	entries, err := os.ReadDir(s.cacheDir)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	var repos []map[string]interface{}
+	repos := []CachedRepo{}
 	for _, entry := range entries {
 		if entry.IsDir() {
 			path := filepath.Join(s.cacheDir, entry.Name())
 			info, _ := entry.Info()
-			repos = append(repos, map[string]interface{}{
-				"name":     entry.Name(),
-				"path":     path,
-				"modified": info.ModTime(),
+			repos = append(repos, CachedRepo{
+				Name:     entry.Name(),
+				Path:     path,
+				Modified: info.ModTime(),
 			})
 		}
 	}
 
-	c.JSON(200, gin.H{
-		"repos": repos,
-		"count": len(repos),
+	c.JSON(200, CacheListResponse{
+		Repos: repos,
+		Count: len(repos),
 	})
 }
 
+// StatusResponse 是只返回结果说明的响应
+type StatusResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
 func (s *Service) handleDeleteCache(c *gin.Context) {
 	repo := c.Param("repo")
+	// 名称只能是缓存目录下的一级目录，".."、"." 或带分隔符的名称会删除缓存目录之外或整个缓存目录
+	if repo == "." || !filepath.IsLocal(repo) || strings.ContainsAny(repo, `/\`) {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid cache name: %q", repo), nil))
+		return
+	}
 	repoPath := filepath.Join(s.cacheDir, repo)
-
-	if err := os.RemoveAll(repoPath); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if filepath.Dir(repoPath) != filepath.Clean(s.cacheDir) {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid cache name: %q", repo), nil))
 		return
 	}
-
-	c.JSON(200, gin.H{"status": "ok", "message": "Cache deleted"})
-}
-
-func (s *Service) handleGetConfig(c *gin.Context) {
-	c.JSON(200, s.config)
-}
-
-func (s *Service) handleUpdateConfig(c *gin.Context) {
-	var newConfig Config
-	if err := c.ShouldBindJSON(&newConfig); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err != nil {
+		respondError(c, newServiceError(ErrCodeNotFound, fmt.Sprintf("Cached repository not found: %s", repo), err))
 		return
 	}
 
-	// 更新配置
-	s.config = &newConfig
-
-	// 保存到文件
-	configPath := filepath.Join(os.Getenv("HOME"), ".github-browser", "config.json")
-	data, _ := json.MarshalIndent(newConfig, "", "  ")
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+	if err := os.RemoveAll(repoPath); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(200, gin.H{"status": "ok", "config": s.config})
+	c.JSON(200, StatusResponse{Status: "ok", Message: "Cache deleted"})
 }
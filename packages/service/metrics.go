@@ -0,0 +1,269 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// version 是服务的版本号，构建时通过 -ldflags "-X main.version=v1.2.3" 设置
+var version = "dev"
+
+// startTime 是服务进程启动的时间
+var startTime = time.Now()
+
+// 打开流程的阶段，用于 OpenResponse.timings 和 stage 指标
+const (
+	StageClone      = "clone"
+	StagePull       = "pull"
+	StageFetch      = "fetch"
+	StageCheckout   = "checkout"
+	StageSubmodules = "submodules"
+	StageLFS        = "lfs"
+	StageHooks      = "hooks"
+	StageLaunch     = "launch"
+)
+
+// cacheStatsTTL 是缓存大小统计的有效期，避免每次抓取指标都遍历缓存目录
+const cacheStatsTTL = time.Minute
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	opensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_browser_opens_total",
+		Help: "Number of /open requests by URL type, IDE and outcome (ok or error code).",
+	}, []string{"type", "ide", "outcome"})
+
+	openDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "github_browser_open_duration_seconds",
+		Help:    "Duration of /open requests by URL type, IDE and outcome.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"type", "ide", "outcome"})
+
+	stageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "github_browser_open_stage_duration_seconds",
+		Help:    "Duration of each stage of opening a repository (clone, pull, fetch, checkout, submodules, lfs, hooks, launch).",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"stage"})
+
+	gitCommandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_browser_git_commands_total",
+		Help: "Number of git subprocesses started, by git subcommand.",
+	}, []string{"command"})
+)
+
+func init() {
+	info := currentBuildInfo()
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "github_browser_build_info",
+		Help:        "Build information of the running service.",
+		ConstLabels: prometheus.Labels{"version": info.Version, "commit": info.Commit, "go_version": info.GoVersion},
+	})
+	buildInfo.Set(1)
+
+	metricsRegistry.MustRegister(
+		opensTotal,
+		openDuration,
+		stageDuration,
+		gitCommandsTotal,
+		buildInfo,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "github_browser_start_time_seconds",
+			Help: "Start time of the service since unix epoch in seconds.",
+		}, func() float64 { return float64(startTime.UnixNano()) / 1e9 }),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// BuildInfo 描述正在运行的服务的版本
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`    // 构建时的 git commit
+	BuildTime string `json:"buildTime,omitempty"` // commit 的时间
+	Modified  bool   `json:"modified,omitempty"`  // 构建时工作区有未提交的修改
+	GoVersion string `json:"goVersion"`
+}
+
+// currentBuildInfo 返回版本号及 Go 工具链记录的 commit 信息
+func currentBuildInfo() BuildInfo {
+	info := BuildInfo{Version: version, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.time":
+				info.BuildTime = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// stageTimer 记录一次打开中各阶段的耗时，并计入 stage 指标
+// 为 nil 时只执行阶段，不记录耗时
+type stageTimer struct {
+	mu      sync.Mutex
+	timings map[string]time.Duration
+}
+
+func newStageTimer() *stageTimer {
+	return &stageTimer{timings: map[string]time.Duration{}}
+}
+
+// time 执行阶段 fn 并记录耗时，同一阶段多次执行时累加
+func (t *stageTimer) time(stage string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	if t != nil {
+		elapsed := time.Since(start)
+		stageDuration.WithLabelValues(stage).Observe(elapsed.Seconds())
+		t.mu.Lock()
+		t.timings[stage] += elapsed
+		t.mu.Unlock()
+	}
+	return err
+}
+
+// milliseconds 返回各阶段的耗时（毫秒），没有记录时返回 nil
+func (t *stageTimer) milliseconds() map[string]int64 {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.timings) == 0 {
+		return nil
+	}
+	out := make(map[string]int64, len(t.timings))
+	for stage, d := range t.timings {
+		out[stage] = d.Milliseconds()
+	}
+	return out
+}
+
+// errorCodeKey 是 respondError 在 gin.Context 中记录错误码的键，用于统计请求结果
+const errorCodeKey = "errorCode"
+
+// observeOpen 记录一次 /open 请求的结果和耗时
+// 由 handleOpen 在返回前调用，结果为 ok 或 respondError 记录的错误码
+// 请求中的 IDE 名称不可信，既不是内置也不是自定义 IDE 时记为 other，避免标签数量无限增长
+func observeOpen(c *gin.Context, urlType URLType, ide string, customIDEs map[string]CustomIDE, start time.Time) {
+	outcome := "ok"
+	if code := c.GetString(errorCodeKey); code != "" {
+		outcome = code
+	}
+	typeLabel := string(urlType)
+	if typeLabel == "" {
+		typeLabel = "unknown"
+	}
+	if ide == "" {
+		ide = "none"
+	} else if _, ok := lookupIDE(ide, customIDEs); !ok {
+		ide = "other"
+	}
+	opensTotal.WithLabelValues(typeLabel, ide, outcome).Inc()
+	openDuration.WithLabelValues(typeLabel, ide, outcome).Observe(time.Since(start).Seconds())
+}
+
+// countGitCommand 记录启动的 git 子进程，标签为 git 子命令（如 clone、fetch）
+func countGitCommand(args []string) {
+	command := "unknown"
+	for i := 0; i < len(args); i++ {
+		// 跳过 -C <dir>、-c key=value 等全局参数
+		if args[i] == "-C" || args[i] == "-c" {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(args[i], "-") {
+			command = args[i]
+			break
+		}
+	}
+	gitCommandsTotal.WithLabelValues(command).Inc()
+}
+
+// cacheCollector 在抓取指标时报告缓存目录中的仓库数量和占用空间
+// 统计结果缓存 cacheStatsTTL，缓存目录随配置变化时重新统计
+type cacheCollector struct {
+	live *liveService
+
+	mu        sync.Mutex
+	cacheDir  string
+	updatedAt time.Time
+	repos     int
+	bytes     int64
+}
+
+var (
+	cacheReposDesc = prometheus.NewDesc("github_browser_cache_repos", "Number of repositories in the cache directory.", nil, nil)
+	cacheBytesDesc = prometheus.NewDesc("github_browser_cache_size_bytes", "Disk space used by repositories in the cache directory.", nil, nil)
+)
+
+func (cc *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheReposDesc
+	ch <- cacheBytesDesc
+}
+
+func (cc *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	s := cc.live.get()
+	if s.cacheDir != cc.cacheDir || time.Since(cc.updatedAt) > cacheStatsTTL {
+		entries, err := s.cacheEntries(true)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(cacheReposDesc, err)
+			return
+		}
+		cc.repos, cc.bytes = len(entries), 0
+		for _, e := range entries {
+			cc.bytes += e.Size
+		}
+		cc.cacheDir, cc.updatedAt = s.cacheDir, time.Now()
+	}
+	ch <- prometheus.MustNewConstMetric(cacheReposDesc, prometheus.GaugeValue, float64(cc.repos))
+	ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(cc.bytes))
+}
+
+// metricsHandler 返回 Prometheus 格式的指标，缓存指标使用 live 中当前的配置
+func metricsHandler(live *liveService) gin.HandlerFunc {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&cacheCollector{live: live})
+	handler := promhttp.HandlerFor(prometheus.Gatherers{metricsRegistry, registry}, promhttp.HandlerOpts{})
+	return gin.WrapH(handler)
+}
+
+// HealthResponse 是 GET /health 的响应
+type HealthResponse struct {
+	Status        string    `json:"status"`
+	Version       string    `json:"version"`
+	Build         BuildInfo `json:"build"`
+	StartedAt     string    `json:"startedAt"` // RFC 3339 格式的启动时间
+	Uptime        string    `json:"uptime"`    // 如 "1h2m3s"
+	UptimeSeconds int64     `json:"uptimeSeconds"`
+}
+
+// handleHealth 返回服务状态、版本和运行时间
+func (s *Service) handleHealth(c *gin.Context) {
+	uptime := time.Since(startTime)
+	c.JSON(200, HealthResponse{
+		Status:        "ok",
+		Version:       version,
+		Build:         currentBuildInfo(),
+		StartedAt:     startTime.UTC().Format(time.RFC3339),
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+	})
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	// NativeHostName 是浏览器扩展调用 connectNative/sendNativeMessage 时使用的名称
+	NativeHostName = "com.github_browser.service"
+
+	// DefaultFirefoxExtensionID 与 browser-ext/manifest.json 中的 gecko id 一致
+	DefaultFirefoxExtensionID = "github-browser@example.com"
+
+	nativeMessageMaxIn  = 64 << 20 // 扩展发送的单条消息上限
+	nativeMessageMaxOut = 1 << 20  // 浏览器限制发给扩展的单条消息不超过 1MB
+)
+
+// NativeMessage 是扩展发送的请求
+type NativeMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"` // 原样返回，便于扩展对应请求和响应
+	Action string          `json:"action"`
+	Params json.RawMessage `json:"params,omitempty"` // 与对应 HTTP 接口的请求体相同
+}
+
+// NativeResponse 是返回给扩展的响应，result 为对应 HTTP 接口的响应体
+type NativeResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	OK     bool            `json:"ok"`
+	Status int             `json:"status"` // 对应的 HTTP 状态码
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// nativeAction 描述一个 action 对应的 HTTP 接口
+type nativeAction struct {
+	method string
+	path   string
+}
+
+// nativeActions 是 native messaging 支持的操作，与 HTTP API 一一对应
+var nativeActions = map[string]nativeAction{
+	"health":       {"GET", "/health"},
+	"open":         {"POST", "/open"},
+	"open.batch":   {"POST", "/open/batch"},
+	"resolve":      {"POST", "/resolve"},
+	"ides":         {"GET", "/ides"},
+	"cache.list":   {"GET", "/cache"},
+	"cache.size":   {"GET", "/cache/size"},
+	"cache.prune":  {"POST", "/cache/prune"},
+	"cache.delete": {"DELETE", "/cache/:repo"}, // params: {"repo": "owner-repo"}
+	"config.get":   {"GET", "/config"},
+	"config.set":   {"PUT", "/config"},
+	"logs":         {"GET", "/logs"}, // params: {"request": "<request id>"}
+
+	"history":          {"GET", "/history"}, // params: {"repo": "owner/repo", "distinct": true, "limit": 10}
+	"history.clear":    {"DELETE", "/history"},
+	"history.reopen":   {"POST", "/history/:id/reopen"}, // params: {"id": "<history id>"}
+	"favorites.list":   {"GET", "/favorites"},
+	"favorites.set":    {"PUT", "/favorites"},
+	"favorites.delete": {"DELETE", "/favorites"}, // params: {"repo": "owner/repo"}
+}
+
+// request 把 params 转换为请求路径和请求体
+// 路径中的 :name 使用 params 中的同名字段，GET 和 DELETE 的其他字段作为查询参数，其他方法作为 JSON 请求体
+func (a nativeAction) request(raw json.RawMessage) (string, interface{}, error) {
+	params := map[string]interface{}{}
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return "", nil, fmt.Errorf("params must be an object")
+		}
+	}
+
+	segments := strings.Split(a.path, "/")
+	for i, seg := range segments {
+		name, ok := strings.CutPrefix(seg, ":")
+		if !ok {
+			continue
+		}
+		value, _ := params[name].(string)
+		if value == "" {
+			return "", nil, fmt.Errorf("params.%s is required", name)
+		}
+		segments[i] = url.PathEscape(value)
+		delete(params, name)
+	}
+	path := strings.Join(segments, "/")
+
+	if a.method == "GET" || a.method == "DELETE" {
+		query := url.Values{}
+		for key, value := range params {
+			query.Set(key, fmt.Sprint(value))
+		}
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+		return path, nil, nil
+	}
+	if len(params) == 0 {
+		return path, nil, nil
+	}
+	return path, params, nil
+}
+
+// isNativeHostInvocation 判断进程是否由浏览器作为 native messaging host 启动
+// Chrome 传入扩展的 origin，Firefox 传入 manifest 路径和扩展 ID
+func isNativeHostInvocation(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if strings.HasPrefix(args[0], "chrome-extension://") {
+		return true
+	}
+	return len(args) == 2 && strings.HasSuffix(args[0], ".json") && filepath.Base(args[0]) == NativeHostName+".json"
+}
+
+// runNativeHost 从 stdin 读取消息并把响应写到 stdout，直到浏览器关闭连接
+// 请求通过 apiClient 交给正在运行的服务，服务未运行时在当前进程中处理
+func runNativeHost(opts cliOptions) int {
+	// stdout 只能写协议消息，其他输出都改到 stderr，浏览器会把 stderr 写入自己的日志
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	log.SetOutput(os.Stderr)
+
+	client, err := newAPIClient(opts)
+	if err != nil {
+		slog.Error("Native host failed", "error", err)
+		return exitError
+	}
+	slog.Info("Native messaging host started", "service", client.addr)
+
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	for {
+		msg, err := readNativeMessage(os.Stdin)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			slog.Error("Native host failed", "error", err)
+			wg.Wait()
+			return exitError
+		}
+
+		// 克隆可能耗时较长，每条消息单独处理，扩展通过 id 对应响应
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := handleNativeMessage(client, msg)
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if err := writeNativeMessage(out, resp); err != nil {
+				slog.Error("Native host failed", "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+	return exitOK
+}
+
+// handleNativeMessage 把消息转换为 HTTP 请求并返回结果
+func handleNativeMessage(client *apiClient, data []byte) *NativeResponse {
+	var msg NativeMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return &NativeResponse{Status: 400, Error: fmt.Sprintf("invalid message: %v", err)}
+	}
+
+	action, ok := nativeActions[msg.Action]
+	if !ok {
+		return &NativeResponse{ID: msg.ID, Status: 400, Error: fmt.Sprintf("unknown action: %s", msg.Action)}
+	}
+
+	path, body, err := action.request(msg.Params)
+	if err != nil {
+		return &NativeResponse{ID: msg.ID, Status: 400, Error: err.Error()}
+	}
+
+	status, result, err := client.call(action.method, path, body)
+	if err != nil {
+		return &NativeResponse{ID: msg.ID, Status: 502, Error: err.Error()}
+	}
+	resp := &NativeResponse{ID: msg.ID, OK: status < 400, Status: status, Result: result}
+	if !json.Valid(result) {
+		resp.OK, resp.Result, resp.Error = false, nil, strings.TrimSpace(string(result))
+	}
+	return resp
+}
+
+// readNativeMessage 读取一条消息：4 字节本机字节序的长度，后跟 UTF-8 JSON
+func readNativeMessage(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.NativeEndian, &length); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated message header")
+		}
+		return nil, err
+	}
+	if length > nativeMessageMaxIn {
+		return nil, fmt.Errorf("message too large: %d bytes", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("truncated message: %v", err)
+	}
+	return data, nil
+}
+
+// writeNativeMessage 写出一条消息，超过浏览器限制时改为返回错误
+func writeNativeMessage(w io.Writer, resp *NativeResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if len(data) > nativeMessageMaxOut {
+		data, _ = json.Marshal(&NativeResponse{ID: resp.ID, Status: resp.Status, Error: "response exceeds the 1MB native messaging limit"})
+	}
+	if err := binary.Write(w, binary.NativeEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// cliNativeHost 处理 native-host 子命令
+func cliNativeHost(opts cliOptions, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: native-host run | install [--chrome ID,...] [--firefox ID,...] | uninstall")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "run":
+		return runNativeHost(opts)
+	case "install", "uninstall":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown native-host command: %s\n", args[0])
+		return exitUsage
+	}
+
+	if runtime.GOOS != "linux" {
+		fmt.Fprintln(os.Stderr, "❌ native-host install is only supported on Linux")
+		return exitError
+	}
+	if args[0] == "uninstall" {
+		for _, file := range nativeManifestPaths() {
+			if err := os.Remove(file); err == nil {
+				fmt.Printf("🗑️  Removed %s\n", file)
+			} else if !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+				return exitError
+			}
+		}
+		return exitOK
+	}
+
+	fs := flag.NewFlagSet("native-host install", flag.ContinueOnError)
+	chrome := fs.String("chrome", "", "comma-separated Chrome/Chromium extension IDs")
+	firefox := fs.String("firefox", "", "comma-separated Firefox extension IDs (default "+DefaultFirefoxExtensionID+" when ~/.mozilla exists)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	// 未指定 --firefox 时只为已安装的 Firefox 注册默认扩展 ID，不创建 ~/.mozilla
+	firefoxIDs := splitList(*firefox)
+	if !flagSet(fs, "firefox") {
+		if _, err := os.Stat(filepath.Join(os.Getenv("HOME"), ".mozilla")); err == nil {
+			firefoxIDs = []string{DefaultFirefoxExtensionID}
+		}
+	}
+	if err := installNativeHost(splitList(*chrome), firefoxIDs); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// chromiumConfigDirs 是 Chromium 系浏览器在 ~/.config 下的目录
+var chromiumConfigDirs = []string{
+	"google-chrome",
+	"google-chrome-beta",
+	"chromium",
+	"microsoft-edge",
+	"BraveSoftware/Brave-Browser",
+}
+
+// nativeManifestPaths 返回所有浏览器的 host manifest 路径，Firefox 在最后
+func nativeManifestPaths() []string {
+	home := os.Getenv("HOME")
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	var paths []string
+	for _, dir := range chromiumConfigDirs {
+		paths = append(paths, filepath.Join(configHome, dir, "NativeMessagingHosts", NativeHostName+".json"))
+	}
+	return append(paths, filepath.Join(home, ".mozilla", "native-messaging-hosts", NativeHostName+".json"))
+}
+
+// installNativeHost 为已安装的浏览器写入 host manifest
+// Chromium 系浏览器只在配置目录已存在时写入，避免为未安装的浏览器创建目录
+func installNativeHost(chromeIDs, firefoxIDs []string) error {
+	if len(chromeIDs) == 0 && len(firefoxIDs) == 0 {
+		return fmt.Errorf("at least one --chrome or --firefox extension ID is required")
+	}
+	for _, id := range chromeIDs {
+		if !isChromeExtensionID(id) {
+			return fmt.Errorf("invalid Chrome extension ID: %q", id)
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+
+	manifest := map[string]interface{}{
+		"name":        NativeHostName,
+		"description": "GitHub Browser service",
+		"path":        executable,
+		"type":        "stdio",
+	}
+
+	paths := nativeManifestPaths()
+	installed := 0
+	if len(chromeIDs) > 0 {
+		origins := make([]string, len(chromeIDs))
+		for i, id := range chromeIDs {
+			origins[i] = "chrome-extension://" + id + "/"
+		}
+		manifest["allowed_origins"] = origins
+		for _, file := range paths[:len(paths)-1] {
+			browserDir := filepath.Dir(filepath.Dir(file))
+			if _, err := os.Stat(browserDir); err != nil {
+				continue
+			}
+			if err := writeNativeManifest(file, manifest); err != nil {
+				return err
+			}
+			installed++
+		}
+		delete(manifest, "allowed_origins")
+	}
+
+	if len(firefoxIDs) > 0 {
+		manifest["allowed_extensions"] = firefoxIDs
+		if err := writeNativeManifest(paths[len(paths)-1], manifest); err != nil {
+			return err
+		}
+		installed++
+	}
+
+	if installed == 0 {
+		return fmt.Errorf("no supported browser found under %s", filepath.Dir(filepath.Dir(filepath.Dir(paths[0]))))
+	}
+	return nil
+}
+
+func writeNativeManifest(file string, manifest map[string]interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("📝 Wrote %s\n", file)
+	return nil
+}
+
+// isChromeExtensionID 检查是否为 32 位 a-p 字母组成的 Chrome 扩展 ID
+func isChromeExtensionID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	for _, c := range id {
+		if c < 'a' || c > 'p' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitList 解析逗号分隔的参数，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// flagSet 判断命令行中是否显式给出了参数 name
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiParam 是接口的查询参数
+type apiParam struct {
+	name        string
+	typ         string // string、integer 或 boolean
+	description string
+}
+
+// apiRoute 描述一个 HTTP 接口
+// router 按 apiRoutes 注册路由，GET /openapi.json 按同一张表生成文档，两者不会不一致
+type apiRoute struct {
+	method       string
+	path         string // gin 格式的路径，如 /cache/:repo
+	id           string // OpenAPI 的 operationId
+	summary      string
+	handler      func(*Service, *gin.Context) // 为 nil 时由 router 单独注册，如 /metrics
+	query        []apiParam
+	request      interface{}   // 请求体类型的零值，nil 表示没有请求体
+	bodyOptional bool          // 请求体可以省略
+	responses    []interface{} // 成功响应体类型的零值，多个表示按查询参数返回不同结构
+	contentType  string        // 响应不是 JSON 时的类型，此时不描述响应结构
+	errors       []interface{} // 错误响应体类型的零值，默认为 OpenResponse
+}
+
+// apiRoutes 是服务的所有 HTTP 接口
+var apiRoutes = []apiRoute{
+	{method: "GET", path: "/health", id: "health", summary: "服务状态、版本和运行时间",
+		handler: (*Service).handleHealth, responses: []interface{}{HealthResponse{}}},
+	{method: "GET", path: "/metrics", id: "metrics", summary: "Prometheus 格式的指标",
+		contentType: "text/plain"},
+	{method: "GET", path: "/openapi.json", id: "openapi", summary: "本文档",
+		contentType: "application/json"},
+	{method: "POST", path: "/open", id: "open", summary: "克隆或更新仓库并在 IDE 中打开",
+		handler: (*Service).handleOpen, request: OpenRequest{}, responses: []interface{}{OpenResponse{}}},
+	{method: "POST", path: "/open/batch", id: "openBatch", summary: "在同一个 IDE 窗口中打开多个仓库",
+		handler: (*Service).handleOpenBatch, request: BatchOpenRequest{},
+		responses: []interface{}{BatchOpenResponse{}}, errors: []interface{}{BatchOpenResponse{}}},
+	{method: "POST", path: "/resolve", id: "resolve", summary: "预演 /open，不克隆也不启动 IDE",
+		handler: (*Service).handleResolve, request: OpenRequest{}, responses: []interface{}{ResolveResponse{}}},
+	{method: "POST", path: "/mappings/test", id: "testMapping", summary: "测试 URL 命中的路径映射规则",
+		handler: (*Service).handleTestMapping, request: MappingTestRequest{},
+		responses: []interface{}{MappingTestResponse{}}, errors: []interface{}{OpenResponse{}, ConfigErrorResponse{}}},
+	{method: "GET", path: "/ides", id: "listIDEs", summary: "IDE 及其安装情况",
+		handler: (*Service).handleListIDEs, responses: []interface{}{IDEListResponse{}}},
+	{method: "GET", path: "/cache", id: "listCache", summary: "缓存目录中的仓库",
+		handler: (*Service).handleListCache, responses: []interface{}{CacheListResponse{}}},
+	{method: "GET", path: "/cache/size", id: "cacheSize", summary: "每个缓存仓库占用的空间",
+		handler: (*Service).handleCacheSize, responses: []interface{}{CacheSizeResponse{}}},
+	{method: "POST", path: "/cache/prune", id: "pruneCache", summary: "删除长时间未使用的缓存仓库",
+		handler: (*Service).handlePruneCache, request: PruneCacheRequest{}, responses: []interface{}{PruneCacheResponse{}}},
+	{method: "DELETE", path: "/cache/:repo", id: "deleteCache", summary: "删除缓存的仓库",
+		handler: (*Service).handleDeleteCache, responses: []interface{}{StatusResponse{}}},
+	{method: "GET", path: "/config", id: "getConfig", summary: "当前配置，explain=1 时同时返回各配置层和每个值的来源",
+		handler:   (*Service).handleGetConfig,
+		query:     []apiParam{{"explain", "boolean", "返回配置层和每个值的来源"}},
+		responses: []interface{}{Config{}, ConfigExplainResponse{}}},
+	{method: "GET", path: "/config/schema", id: "configSchema", summary: "配置文件的 JSON Schema",
+		handler: (*Service).handleConfigSchema, contentType: "application/schema+json"},
+	{method: "PUT", path: "/config", id: "updateConfig", summary: "替换配置，省略 githubToken 时保留当前的 token",
+		handler: (*Service).handleUpdateConfig, request: Config{},
+		responses: []interface{}{ConfigUpdateResponse{}}, errors: []interface{}{ConfigErrorResponse{}}},
+	{method: "PATCH", path: "/config", id: "patchConfig", summary: "按 JSON Merge Patch 修改用户配置",
+		handler: (*Service).handlePatchConfig, request: map[string]interface{}{},
+		responses: []interface{}{ConfigUpdateResponse{}}, errors: []interface{}{ConfigErrorResponse{}}},
+	{method: "GET", path: "/logs", id: "logs", summary: "日志文件中的记录",
+		handler: (*Service).handleLogs,
+		query: []apiParam{
+			{"request", "string", "只返回该请求 ID 的日志"},
+			{"level", "string", "最低级别：debug、info、warn 或 error"},
+			{"limit", "integer", "返回最后多少条，默认 200，最大 5000"},
+		},
+		responses: []interface{}{LogsResponse{}}},
+	{method: "GET", path: "/history", id: "listHistory", summary: "打开历史，按时间从新到旧排列",
+		handler: (*Service).handleListHistory,
+		query: []apiParam{
+			{"repo", "string", "owner/repo 或 owner"},
+			{"q", "string", "在 URL、仓库和文件路径中查找"},
+			{"status", "string", "ok 或 error"},
+			{"ide", "string", "使用的 IDE"},
+			{"since", "string", "RFC 3339 时间，或 7d、24h 这样的相对时间"},
+			{"distinct", "boolean", "每个仓库只返回最近的一条"},
+			{"limit", "integer", "默认 50，最大 500"},
+			{"offset", "integer", "跳过的记录数"},
+		},
+		responses: []interface{}{HistoryResponse{}}},
+	{method: "DELETE", path: "/history", id: "clearHistory", summary: "删除打开历史",
+		handler:   (*Service).handleClearHistory,
+		query:     []apiParam{{"repo", "string", "只删除该仓库的记录"}},
+		responses: []interface{}{ClearHistoryResponse{}}},
+	{method: "POST", path: "/history/:id/reopen", id: "reopen", summary: "按历史记录再次打开",
+		handler: (*Service).handleReopen, request: ReopenRequest{}, bodyOptional: true,
+		responses: []interface{}{OpenResponse{}}},
+	{method: "GET", path: "/favorites", id: "listFavorites", summary: "收藏的仓库",
+		handler: (*Service).handleListFavorites, responses: []interface{}{FavoritesResponse{}}},
+	{method: "PUT", path: "/favorites", id: "putFavorite", summary: "收藏仓库，已收藏时更新",
+		handler: (*Service).handlePutFavorite, request: FavoriteRequest{}, responses: []interface{}{FavoriteResponse{}}},
+	{method: "DELETE", path: "/favorites", id: "deleteFavorite", summary: "取消收藏",
+		handler: (*Service).handleDeleteFavorite,
+		query: []apiParam{
+			{"repo", "string", "owner/repo"},
+			{"url", "string", "仓库的 GitHub URL，与 repo 二选一"},
+		},
+		responses: []interface{}{DeleteFavoriteResponse{}}},
+}
+
+// apiEnums 是字符串类型在文档中列出的取值
+var apiEnums = map[reflect.Type][]string{
+	reflect.TypeOf(ErrorCode("")): func() []string {
+		values := make([]string, len(errorCodes))
+		for i, code := range errorCodes {
+			values[i] = string(code)
+		}
+		return values
+	}(),
+	reflect.TypeOf(URLType("")): {string(URLTypeRepo), string(URLTypePR)},
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	servicePkgPath = reflect.TypeOf(Config{}).PkgPath()
+)
+
+// ginParamPattern 匹配 gin 路径中的 :name 参数
+var ginParamPattern = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// openAPIBuilder 根据 Go 类型生成 schema，服务中定义的具名类型放在 components 中按类型名引用
+type openAPIBuilder struct {
+	schemas map[string]interface{}
+}
+
+// openAPIDocument 返回描述 apiRoutes 的 OpenAPI 3 文档
+func openAPIDocument(serverURL string) map[string]interface{} {
+	b := &openAPIBuilder{schemas: map[string]interface{}{}}
+	paths := map[string]map[string]interface{}{}
+	for _, route := range apiRoutes {
+		path := ginParamPattern.ReplaceAllString(route.path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(route.method)] = b.operation(route)
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "GitHub Browser Service",
+			"description": "在本地 IDE 中打开 GitHub 仓库、PR 和文件的本地服务",
+			"version":     version,
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": serverURL}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": b.schemas},
+	}
+}
+
+// operation 返回一个接口的描述
+func (b *openAPIBuilder) operation(route apiRoute) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": route.id,
+		"summary":     route.summary,
+	}
+
+	params := []interface{}{}
+	for _, m := range ginParamPattern.FindAllStringSubmatch(route.path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, q := range route.query {
+		params = append(params, map[string]interface{}{
+			"name":        q.name,
+			"in":          "query",
+			"description": q.description,
+			"schema":      map[string]interface{}{"type": q.typ},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": !route.bodyOptional,
+			"content":  jsonContent(b.oneOf([]interface{}{route.request})),
+		}
+	}
+
+	success := map[string]interface{}{"description": "OK"}
+	if route.contentType != "" {
+		success["content"] = map[string]interface{}{route.contentType: map[string]interface{}{}}
+	} else {
+		success["content"] = jsonContent(b.oneOf(route.responses))
+	}
+	responses := map[string]interface{}{"200": success}
+	if route.handler != nil && route.contentType == "" {
+		errors := route.errors
+		if errors == nil {
+			errors = []interface{}{OpenResponse{}}
+		}
+		responses["default"] = map[string]interface{}{
+			"description": "错误，code 为错误码，HTTP 状态码由错误码决定",
+			"content":     jsonContent(b.oneOf(errors)),
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// oneOf 返回 values 的类型对应的 schema，多个类型时为 oneOf
+func (b *openAPIBuilder) oneOf(values []interface{}) map[string]interface{} {
+	if len(values) == 1 {
+		return b.schema(reflect.TypeOf(values[0]))
+	}
+	schemas := make([]interface{}, len(values))
+	for i, v := range values {
+		schemas[i] = b.schema(reflect.TypeOf(v))
+	}
+	return map[string]interface{}{"oneOf": schemas}
+}
+
+// schema 返回类型 t 按 encoding/json 序列化后的 schema
+func (b *openAPIBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+	if t.Kind() == reflect.Pointer {
+		return b.schema(t.Elem())
+	}
+	if t.PkgPath() == servicePkgPath && t.Name() != "" {
+		return b.ref(t)
+	}
+	return b.inline(t)
+}
+
+// ref 把具名类型加入 components，返回对它的引用
+func (b *openAPIBuilder) ref(t reflect.Type) map[string]interface{} {
+	name := schemaName(t)
+	if _, ok := b.schemas[name]; !ok {
+		// 先占位，类型引用自身时不会无限递归
+		b.schemas[name] = nil
+		b.schemas[name] = b.inline(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// inline 返回类型 t 本身的 schema
+func (b *openAPIBuilder) inline(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		schema := map[string]interface{}{"type": "string"}
+		if values, ok := apiEnums[t]; ok {
+			schema["enum"] = values
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		return b.object(t)
+	default:
+		// interface{} 等任意 JSON 值
+		return map[string]interface{}{}
+	}
+}
+
+// object 返回结构体的 schema，字段名和省略规则与 encoding/json 一致
+// binding:"required" 的字段为必填
+func (b *openAPIBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = b.schema(f.Type)
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName 返回类型在 components 中的名称，未导出的类型名首字母大写，如 configLayer → ConfigLayer
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// handleOpenAPI 返回描述所有接口的 OpenAPI 3 文档
+func (s *Service) handleOpenAPI(c *gin.Context) {
+	port := s.config.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	c.JSON(200, openAPIDocument(fmt.Sprintf("http://localhost:%d", port)))
+}
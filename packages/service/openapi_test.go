@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var updateGolden = flag.Bool("update", false, "update testdata/openapi.json")
+
+// TestOpenAPIGolden 比较 /openapi.json 与 testdata 中的文档，API 的改动需要同时更新该文件
+//
+//	go test -run TestOpenAPIGolden -update
+func TestOpenAPIGolden(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	service, err := newService(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	service.router().ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rec.Code != 200 {
+		t.Fatalf("GET /openapi.json: HTTP %d: %s", rec.Code, rec.Body)
+	}
+	var got bytes.Buffer
+	if err := json.Indent(&got, rec.Body.Bytes(), "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	got.WriteByte('\n')
+
+	golden := filepath.Join("testdata", "openapi.json")
+	if *updateGolden {
+		if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("/openapi.json differs from %s; review the API change and run go test -run TestOpenAPIGolden -update", golden)
+	}
+}
+
+// TestClientTypesUpToDate 重新生成 client/types.go，检查是否忘记运行 go generate
+func TestClientTypesUpToDate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the service to generate client types")
+	}
+	output := filepath.Join(t.TempDir(), "types.go")
+	cmd := exec.Command("go", "run", "./internal/gen", "-o", output)
+	cmd.Dir = "client"
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("client/internal/gen: %v\n%s", err, out)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("client", "types.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("client/types.go is out of date; run go generate ./... in the client directory")
+	}
+}
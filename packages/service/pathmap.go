@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultGitHubHost 是 GitHub URL 的主机，路径映射的 host 和 {host} 占位符使用该值
+const DefaultGitHubHost = "github.com"
+
+// defaultTemplateRef 是 URL 未指定 ref 时 {ref} 的值
+const defaultTemplateRef = "HEAD"
+
+// templateVarPattern 匹配 localPath 中的 {name} 占位符
+var templateVarPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// mappingTemplateVars 是 localPath 模板内置的占位符，正则表达式的命名分组不能与之重名
+var mappingTemplateVars = []string{"host", "owner", "repo", "ref"}
+
+// MappingMatch 描述仓库命中的路径映射规则及本地路径
+type MappingMatch struct {
+	Index   int               `json:"index"`             // 命中的规则在 pathMappings 中的下标，-1 表示使用默认 cacheDir
+	Mapping *PathMapping      `json:"mapping,omitempty"` // 命中的规则
+	Path    string            `json:"path"`              // 本地仓库路径
+	Vars    map[string]string `json:"vars,omitempty"`    // 展开 localPath 时使用的变量
+}
+
+// mappingRule 是编译后的路径映射规则
+type mappingRule struct {
+	index       int
+	mapping     *PathMapping
+	regex       *regexp.Regexp
+	specificity int
+}
+
+// pathResolver 按优先级匹配 pathMappings，计算仓库的本地路径
+// 规则按 priority 从大到小匹配，相同时更具体的模式优先（见 patternSpecificity），再相同时按配置顺序
+type pathResolver struct {
+	rules    []*mappingRule // 按匹配顺序排列
+	cacheDir string
+}
+
+// newPathResolver 编译路径映射规则，cacheDir 为空时使用默认缓存目录
+func newPathResolver(mappings []PathMapping, cacheDir string) (*pathResolver, error) {
+	if cacheDir == "" {
+		cacheDir = expandPath("~/" + DefaultCacheDir)
+	}
+	r := &pathResolver{cacheDir: expandPath(cacheDir)}
+	for i := range mappings {
+		rule := &mappingRule{index: i, mapping: &mappings[i]}
+		if m := mappings[i]; m.Regex != "" {
+			re, err := regexp.Compile(m.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("pathMappings[%d].regex: %v", i, err)
+			}
+			rule.regex, rule.specificity = re, 1
+		} else {
+			rule.specificity = patternSpecificity(m.Pattern)
+		}
+		r.rules = append(r.rules, rule)
+	}
+	sort.SliceStable(r.rules, func(i, j int) bool {
+		a, b := r.rules[i], r.rules[j]
+		if a.mapping.Priority != b.mapping.Priority {
+			return a.mapping.Priority > b.mapping.Priority
+		}
+		return a.specificity > b.specificity
+	})
+	return r, nil
+}
+
+// patternSpecificity 返回模式的具体程度，数值大的先匹配
+//   - 4："owner/repo"
+//   - 3：owner 固定、repo 带通配符，如 "myorg/svc-*"
+//   - 2："owner" 或 "owner/*"
+//   - 1：owner 带通配符，如 "*/infra-*"；正则表达式也视为此级别
+//   - 0："*" 或 "*/*"
+func patternSpecificity(pattern string) int {
+	owner, repo, ok := strings.Cut(pattern, "/")
+	if !ok {
+		repo = "*"
+	}
+	switch {
+	case strings.ContainsAny(owner, "*?["):
+		if owner == "*" && repo == "*" {
+			return 0
+		}
+		return 1
+	case repo == "*":
+		return 2
+	case strings.ContainsAny(repo, "*?["):
+		return 3
+	default:
+		return 4
+	}
+}
+
+// mappingVars 返回 URL 对应的模板变量
+func mappingVars(info *GitHubURLInfo) map[string]string {
+	ref := info.Branch
+	if info.Type == URLTypePR {
+		ref = fmt.Sprintf("pr-%d", info.PRNumber)
+	}
+	if ref == "" {
+		ref = defaultTemplateRef
+	}
+	return map[string]string{
+		"host":  DefaultGitHubHost,
+		"owner": info.Owner,
+		"repo":  info.Repo,
+		// ref 可能包含 /，作为目录名时替换掉
+		"ref": strings.NewReplacer("/", "-", `\`, "-").Replace(ref),
+	}
+}
+
+// match 判断规则是否匹配，匹配时返回加入了正则命名分组的模板变量
+func (rule *mappingRule) match(vars map[string]string) (map[string]string, bool) {
+	m := rule.mapping
+	if m.Host != "" {
+		if ok, _ := path.Match(strings.ToLower(m.Host), vars["host"]); !ok {
+			return nil, false
+		}
+	}
+	if rule.regex == nil {
+		return vars, matchRepoPattern(m.Pattern, vars["owner"], vars["repo"])
+	}
+
+	groups := rule.regex.FindStringSubmatch(vars["owner"] + "/" + vars["repo"])
+	if groups == nil {
+		return nil, false
+	}
+	matched := make(map[string]string, len(vars)+len(groups))
+	for k, v := range vars {
+		matched[k] = v
+	}
+	for i, name := range rule.regex.SubexpNames() {
+		if name != "" {
+			matched[name] = groups[i]
+		}
+	}
+	return matched, true
+}
+
+// localPath 展开规则的 localPath
+// 没有占位符时沿用原来的目录结构：单个仓库直接使用 localPath，固定 owner 时为 localPath/repo，其他为 localPath/owner-repo
+func (r *pathResolver) localPath(rule *mappingRule, vars map[string]string) string {
+	tmpl := rule.mapping.LocalPath
+	if !templateVarPattern.MatchString(tmpl) {
+		switch rule.specificity {
+		case 4:
+		case 3, 2:
+			tmpl = filepath.Join(tmpl, "{repo}")
+		default:
+			tmpl = filepath.Join(tmpl, "{owner}-{repo}")
+		}
+	}
+	local := templateVarPattern.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		return vars[placeholder[1:len(placeholder)-1]]
+	})
+	local = expandPath(local)
+	if !filepath.IsAbs(local) {
+		local = filepath.Join(r.cacheDir, local)
+	}
+	return filepath.Clean(local)
+}
+
+// resolve 返回 URL 命中的规则及本地仓库路径，没有规则匹配时使用 cacheDir/owner-repo
+func (r *pathResolver) resolve(info *GitHubURLInfo) MappingMatch {
+	vars := mappingVars(info)
+	for _, rule := range r.rules {
+		if matched, ok := rule.match(vars); ok {
+			return MappingMatch{
+				Index:   rule.index,
+				Mapping: rule.mapping,
+				Path:    r.localPath(rule, matched),
+				Vars:    matched,
+			}
+		}
+	}
+	return MappingMatch{Index: -1, Path: filepath.Join(r.cacheDir, info.Owner+"-"+info.Repo), Vars: vars}
+}
+
+// MappingCandidate 描述一条规则对某个 URL 的匹配结果
+type MappingCandidate struct {
+	Index   int          `json:"index"`
+	Mapping *PathMapping `json:"mapping"`
+	Matched bool         `json:"matched"`
+	Path    string       `json:"path,omitempty"` // 规则匹配时展开的本地路径
+}
+
+// explain 按匹配顺序返回每条规则是否匹配
+func (r *pathResolver) explain(info *GitHubURLInfo) []MappingCandidate {
+	vars := mappingVars(info)
+	candidates := make([]MappingCandidate, 0, len(r.rules))
+	for _, rule := range r.rules {
+		c := MappingCandidate{Index: rule.index, Mapping: rule.mapping}
+		if matched, ok := rule.match(vars); ok {
+			c.Matched, c.Path = true, r.localPath(rule, matched)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+// gitStrategy 返回仓库使用的克隆/拉取策略
+// 按优先级合并：命中的 PathMapping > 全局配置 > 默认值
+func (s *Service) gitStrategy(info *GitHubURLInfo) GitStrategy {
+	strategy := DefaultGitStrategy().merge(s.config.Git)
+	if m := s.paths.resolve(info); m.Mapping != nil {
+		strategy = strategy.merge(m.Mapping.Git)
+	}
+	return strategy
+}
+
+// MappingTestRequest 是 POST /mappings/test 的请求
+type MappingTestRequest struct {
+	URL          string        `json:"url" binding:"required"`
+	PathMappings []PathMapping `json:"pathMappings,omitempty"` // 要测试的规则，省略时使用当前配置
+}
+
+// MappingTestResponse 是 POST /mappings/test 的响应
+type MappingTestResponse struct {
+	Status string             `json:"status"`
+	URL    *GitHubURLInfo     `json:"url"`
+	Match  MappingMatch       `json:"match"` // 实际使用的规则和路径
+	Rules  []MappingCandidate `json:"rules"` // 按匹配顺序排列的所有规则
+}
+
+// handleTestMapping 返回 URL 会命中哪条路径映射规则，可以用请求中的规则代替当前配置进行测试
+func (s *Service) handleTestMapping(c *gin.Context) {
+	var req MappingTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid request: %v", err), err))
+		return
+	}
+
+	info, err := ParseGitHubURL(req.URL)
+	if err != nil {
+		respondError(c, fmt.Errorf("Invalid GitHub URL: %w", err))
+		return
+	}
+
+	resolver := s.paths
+	if req.PathMappings != nil {
+		v := &ValidationError{}
+		for i, m := range req.PathMappings {
+			v.add(fmt.Sprintf("pathMappings[%d]", i), m.validate())
+		}
+		if err := v.err(); err != nil {
+			respondConfigError(c, newServiceError(ErrCodeInvalidRequest, "Invalid path mappings", err))
+			return
+		}
+		if resolver, err = newPathResolver(req.PathMappings, s.cacheDir); err != nil {
+			respondError(c, newServiceError(ErrCodeInvalidRequest, err.Error(), err))
+			return
+		}
+	}
+
+	c.JSON(200, MappingTestResponse{
+		Status: "ok",
+		URL:    info,
+		Match:  resolver.resolve(info),
+		Rules:  resolver.explain(info),
+	})
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// legacyRepoPath 是引入 pathResolver 之前的 Config.GetRepoPath
+// 按优先级匹配：owner/repo > owner > * > 默认 cacheDir
+func legacyRepoPath(mappings []PathMapping, cacheDir, owner, repo string) string {
+	exactMatch := owner + "/" + repo
+	for _, m := range mappings {
+		if m.Pattern == exactMatch {
+			return expandPath(m.LocalPath)
+		}
+	}
+	for _, m := range mappings {
+		if m.Pattern == owner {
+			return filepath.Join(expandPath(m.LocalPath), repo)
+		}
+	}
+	for _, m := range mappings {
+		if m.Pattern == "*" {
+			return filepath.Join(expandPath(m.LocalPath), owner+"-"+repo)
+		}
+	}
+	return filepath.Join(cacheDir, owner+"-"+repo)
+}
+
+func repoURL(owner, repo string) *GitHubURLInfo {
+	return &GitHubURLInfo{Owner: owner, Repo: repo, Type: URLTypeRepo}
+}
+
+func TestPathResolverLegacyLayouts(t *testing.T) {
+	const cacheDir = "/cache"
+	layouts := []struct {
+		name     string
+		mappings []PathMapping
+	}{
+		{"none", nil},
+		{"owner/repo", []PathMapping{{Pattern: "microsoft/vscode", LocalPath: "/src/vscode"}}},
+		{"owner", []PathMapping{{Pattern: "microsoft", LocalPath: "/src/microsoft"}}},
+		{"wildcard", []PathMapping{{Pattern: "*", LocalPath: "/src/all"}}},
+		{"home", []PathMapping{{Pattern: "microsoft", LocalPath: "~/work/microsoft"}}},
+		// 旧版本不管配置顺序，总是 owner/repo > owner > *
+		{"all", []PathMapping{
+			{Pattern: "*", LocalPath: "/src/all"},
+			{Pattern: "microsoft", LocalPath: "/src/microsoft"},
+			{Pattern: "microsoft/vscode", LocalPath: "/src/vscode"},
+			{Pattern: "golang", LocalPath: "/src/go"},
+		}},
+	}
+	repos := [][2]string{
+		{"microsoft", "vscode"},
+		{"microsoft", "typescript"},
+		{"golang", "go"},
+		{"torvalds", "linux"},
+	}
+	for _, layout := range layouts {
+		t.Run(layout.name, func(t *testing.T) {
+			r, err := newPathResolver(layout.mappings, cacheDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, repo := range repos {
+				want := legacyRepoPath(layout.mappings, cacheDir, repo[0], repo[1])
+				if got := r.resolve(repoURL(repo[0], repo[1])).Path; got != want {
+					t.Errorf("%s/%s: path = %s, want %s", repo[0], repo[1], got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPathResolverResolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		mappings  []PathMapping
+		info      *GitHubURLInfo
+		wantIndex int
+		wantPath  string
+	}{
+		{
+			name: "specific pattern before wildcard",
+			mappings: []PathMapping{
+				{Pattern: "*/*", LocalPath: "/src/all"},
+				{Pattern: "*/infra-*", LocalPath: "/src/infra"},
+				{Pattern: "myorg/*", LocalPath: "/src/myorg"},
+				{Pattern: "myorg/svc-*", LocalPath: "/src/svc"},
+			},
+			info:      repoURL("myorg", "svc-api"),
+			wantIndex: 3,
+			wantPath:  "/src/svc/svc-api",
+		},
+		{
+			name: "owner before owner glob",
+			mappings: []PathMapping{
+				{Pattern: "*/infra-*", LocalPath: "/src/infra"},
+				{Pattern: "myorg", LocalPath: "/src/myorg"},
+			},
+			info:      repoURL("myorg", "infra-dns"),
+			wantIndex: 1,
+			wantPath:  "/src/myorg/infra-dns",
+		},
+		{
+			name: "owner glob before catch-all",
+			mappings: []PathMapping{
+				{Pattern: "*", LocalPath: "/src/all"},
+				{Pattern: "*/infra-*", LocalPath: "/src/infra"},
+			},
+			info:      repoURL("acme", "infra-dns"),
+			wantIndex: 1,
+			wantPath:  "/src/infra/acme-infra-dns",
+		},
+		{
+			name: "priority before specificity",
+			mappings: []PathMapping{
+				{Pattern: "myorg/svc-api", LocalPath: "/src/api"},
+				{Pattern: "*", LocalPath: "/src/all", Priority: 10},
+			},
+			info:      repoURL("myorg", "svc-api"),
+			wantIndex: 1,
+			wantPath:  "/src/all/myorg-svc-api",
+		},
+		{
+			name: "config order breaks ties",
+			mappings: []PathMapping{
+				{Pattern: "myorg/svc-*", LocalPath: "/src/first"},
+				{Pattern: "myorg/*-api", LocalPath: "/src/second"},
+			},
+			info:      repoURL("myorg", "svc-api"),
+			wantIndex: 0,
+			wantPath:  "/src/first/svc-api",
+		},
+		{
+			name: "host glob matches",
+			mappings: []PathMapping{
+				{Pattern: "*", Host: "GIT*.com", LocalPath: "/src/github"},
+			},
+			info:      repoURL("acme", "web"),
+			wantIndex: 0,
+			wantPath:  "/src/github/acme-web",
+		},
+		{
+			name: "host mismatch falls through",
+			mappings: []PathMapping{
+				{Pattern: "acme/web", Host: "gitlab.com", LocalPath: "/src/gitlab"},
+				{Pattern: "acme", Host: "github.com", LocalPath: "/src/acme"},
+			},
+			info:      repoURL("acme", "web"),
+			wantIndex: 1,
+			wantPath:  "/src/acme/web",
+		},
+		{
+			name: "regex named groups",
+			mappings: []PathMapping{
+				{Regex: `^(?P<team>[a-z]+)-org/(?P<svc>.+)$`, LocalPath: "/src/{team}/{svc}"},
+			},
+			info:      repoURL("payments-org", "ledger"),
+			wantIndex: 0,
+			wantPath:  "/src/payments/ledger",
+		},
+		{
+			name: "regex without placeholders",
+			mappings: []PathMapping{
+				{Regex: `^acme/`, LocalPath: "/src/acme"},
+			},
+			info:      repoURL("acme", "web"),
+			wantIndex: 0,
+			wantPath:  "/src/acme/acme-web",
+		},
+		{
+			name: "regex mismatch uses cacheDir",
+			mappings: []PathMapping{
+				{Regex: `^acme/`, LocalPath: "/src/acme"},
+			},
+			info:      repoURL("other", "web"),
+			wantIndex: -1,
+			wantPath:  "/cache/other-web",
+		},
+		{
+			name: "template with host and ref",
+			mappings: []PathMapping{
+				{Pattern: "*", LocalPath: "/src/{host}/{owner}/{repo}@{ref}"},
+			},
+			info:      &GitHubURLInfo{Owner: "acme", Repo: "web", Type: URLTypeRepo, Branch: "feature/login"},
+			wantIndex: 0,
+			wantPath:  "/src/github.com/acme/web@feature-login",
+		},
+		{
+			name: "template ref for pull request",
+			mappings: []PathMapping{
+				{Pattern: "acme/web", LocalPath: "/src/{repo}-{ref}"},
+			},
+			info:      &GitHubURLInfo{Owner: "acme", Repo: "web", Type: URLTypePR, PRNumber: 42},
+			wantIndex: 0,
+			wantPath:  "/src/web-pr-42",
+		},
+		{
+			name: "template default ref",
+			mappings: []PathMapping{
+				{Pattern: "acme/web", LocalPath: "/src/{repo}/{ref}"},
+			},
+			info:      repoURL("acme", "web"),
+			wantIndex: 0,
+			wantPath:  "/src/web/HEAD",
+		},
+		{
+			name: "relative path under cacheDir",
+			mappings: []PathMapping{
+				{Pattern: "acme", LocalPath: "mirrors/{owner}/{repo}"},
+			},
+			info:      repoURL("acme", "web"),
+			wantIndex: 0,
+			wantPath:  "/cache/mirrors/acme/web",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newPathResolver(tt.mappings, "/cache")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := r.resolve(tt.info)
+			if m.Index != tt.wantIndex || m.Path != tt.wantPath {
+				t.Errorf("resolve = %d %s, want %d %s", m.Index, m.Path, tt.wantIndex, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestPatternSpecificity(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"microsoft/vscode", 4},
+		{"myorg/svc-*", 3},
+		{"myorg/svc-?", 3},
+		{"microsoft", 2},
+		{"microsoft/*", 2},
+		{"*/infra-*", 1},
+		{"my*", 1},
+		{"*", 0},
+		{"*/*", 0},
+	}
+	for _, tt := range tests {
+		if got := patternSpecificity(tt.pattern); got != tt.want {
+			t.Errorf("patternSpecificity(%q) = %d, want %d", tt.pattern, got, tt.want)
+		}
+	}
+}
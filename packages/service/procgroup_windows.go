@@ -0,0 +1,13 @@
+//go:build windows
+
+package main
+
+import "os/exec"
+
+// setProcessGroup 在 Windows 上不做处理，取消时只结束命令本身
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// setTerminateOnCancel 在 Windows 上取消时直接结束命令，cancelGracePeriod 后不再等待其输出
+func setTerminateOnCancel(cmd *exec.Cmd) {
+	cmd.WaitDelay = cancelGracePeriod
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// configPollInterval 是检查配置文件变化的间隔
+const configPollInterval = 2 * time.Second
+
+// liveService 保存当前生效的 Service，配置更新或重新加载时整体替换
+// 每个请求开始时取出当前的 Service，处理过程中不受之后的配置变化影响
+type liveService struct {
+	mu      sync.RWMutex
+	current *Service
+	layered *layeredConfig    // 当前配置的各层及每个值的来源
+	digest  [sha256.Size]byte // 最近一次加载或写入的配置文件和环境变量的摘要，用于忽略服务自己的写入
+	fileErr error             // 该内容无法加载时的错误
+}
+
+// newLiveService 创建 liveService，记录启动时各配置层的内容和是否有效
+func newLiveService(service *Service) *liveService {
+	l := &liveService{current: service}
+	var layers []*configLayer
+	layers, l.digest, l.fileErr = readConfigLayers()
+	if l.fileErr == nil {
+		l.layered, l.fileErr = resolveLayers(layers)
+	}
+	if l.layered == nil {
+		l.layered = &layeredConfig{config: service.config}
+	}
+	return l
+}
+
+// get 返回当前生效的 Service
+func (l *liveService) get() *Service {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.current
+}
+
+// explain 返回当前配置的各层及每个值的来源
+func (l *liveService) explain() *layeredConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.layered
+}
+
+// handle 把 Service 的方法包装为使用当前配置处理请求的 Gin handler，日志带上请求 ID
+func (l *liveService) handle(fn func(*Service, *gin.Context)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fn(l.get().forRequest(c), c)
+	}
+}
+
+// update 在锁内根据当前配置计算新的用户配置层，合并校验后写入用户配置文件并替换 Service
+// change 返回的错误视为请求错误，保存失败视为内部错误
+func (l *liveService) update(change func(current *layeredConfig) (map[string]interface{}, error)) (*Config, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 先应用配置文件中尚未加载的修改；文件无效时不覆盖，避免丢失用户的编辑
+	if err := l.reloadLocked(); err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "Config files are invalid, fix them before updating the config", err)
+	}
+
+	user, err := change(l.layered)
+	var next *layeredConfig
+	if err == nil {
+		next, err = resolveLayers(withUserLayer(l.layered.layers, user))
+	}
+	if err != nil {
+		// 字段错误在响应的 errors 中逐条列出
+		message := "Invalid config"
+		if fieldErrors(err) == nil {
+			message += ": " + err.Error()
+		}
+		return nil, newServiceError(ErrCodeInvalidRequest, message, err)
+	}
+
+	service, err := l.build(next.config)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeUserConfig(user); err != nil {
+		return nil, err
+	}
+	_, l.digest, _ = readConfigLayers()
+	l.fileErr = nil
+	l.current, l.layered = service, next
+	return next.config, nil
+}
+
+// build 根据配置创建新的 Service，调用方需持有锁
+func (l *liveService) build(config *Config) (*Service, error) {
+	service, err := buildService(config)
+	if err != nil {
+		return nil, err
+	}
+	service.live = l
+	logLevel.Set(config.Log.level())
+	if config.Port != l.current.config.Port {
+		slog.Warn("Port changed, restart the service to apply it", "port", config.Port)
+	}
+	return service, nil
+}
+
+// watch 定期检查配置文件，内容变化时重新加载，文件无效时保留当前配置
+func (l *liveService) watch() {
+	for range time.Tick(configPollInterval) {
+		l.mu.Lock()
+		previous := l.digest
+		if err := l.reloadLocked(); err != nil && l.digest != previous {
+			slog.Warn("Config file changed but is invalid, keeping current config", "error", err)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// reloadLocked 在任一配置层变化时重新加载，调用方需持有锁
+// 配置无效时返回错误，内容未变化时返回上次的结果
+func (l *liveService) reloadLocked() error {
+	layers, digest, err := readConfigLayers()
+	if digest == l.digest {
+		return l.fileErr
+	}
+	l.digest = digest
+
+	var next *layeredConfig
+	if err == nil {
+		next, err = resolveLayers(layers)
+	}
+	if err == nil {
+		var service *Service
+		if service, err = l.build(next.config); err == nil {
+			l.current, l.layered = service, next
+			slog.Info("Reloaded config")
+		}
+	}
+	l.fileErr = err
+	return err
+}
+
+// ConfigExplainResponse 是 GET /config?explain=1 的响应
+type ConfigExplainResponse struct {
+	Config  *Config           `json:"config"`
+	Layers  []*configLayer    `json:"layers"`  // 按优先级从低到高排列的配置层
+	Origins map[string]string `json:"origins"` // 字段路径 → 提供该值的配置层
+}
+
+// ConfigUpdateResponse 是 PUT /config 和 PATCH /config 的响应
+type ConfigUpdateResponse struct {
+	Status string  `json:"status"`
+	Config *Config `json:"config"` // 更新后生效的配置
+}
+
+// ConfigErrorResponse 是配置相关接口的错误响应，errors 列出每个字段的问题
+type ConfigErrorResponse struct {
+	Status  string       `json:"status"`
+	Code    ErrorCode    `json:"code"`
+	Message string       `json:"message"`
+	Error   string       `json:"error"` // 与 message 相同，兼容旧版本客户端
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// handleGetConfig 返回当前配置，explain=1 时同时返回各配置层和每个值的来源
+func (s *Service) handleGetConfig(c *gin.Context) {
+	if explain := c.Query("explain"); explain == "" || explain == "0" || explain == "false" {
+		c.JSON(200, s.config)
+		return
+	}
+	lc := s.live.explain()
+	layers := lc.layers
+	if layers == nil {
+		layers = []*configLayer{}
+	}
+	c.JSON(200, ConfigExplainResponse{
+		Config:  lc.config,
+		Layers:  layers,
+		Origins: lc.origins,
+	})
+}
+
+// handleConfigSchema 返回配置文件的 JSON Schema
+func (s *Service) handleConfigSchema(c *gin.Context) {
+	c.Data(200, "application/schema+json", configSchema)
+}
+
+// handleUpdateConfig 用请求中的配置替换当前配置，用户配置文件只保存与下层配置不同的部分
+func (s *Service) handleUpdateConfig(c *gin.Context) {
+	var raw map[string]interface{}
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		respondConfigError(c, newServiceError(ErrCodeInvalidRequest, "Invalid request: "+err.Error(), err))
+		return
+	}
+
+	config, err := s.live.update(func(current *layeredConfig) (map[string]interface{}, error) {
+		// 省略 githubToken 时保留当前的 token，避免客户端回写配置时把它清空
+		if _, ok := raw["githubToken"]; !ok {
+			raw["githubToken"] = current.config.GitHubToken
+		}
+		config, err := configFromMap(raw)
+		if err != nil {
+			return nil, err
+		}
+		return userLayerDiff(config, current.layers)
+	})
+	if err != nil {
+		respondConfigError(c, err)
+		return
+	}
+	c.JSON(200, ConfigUpdateResponse{Status: "ok", Config: config})
+}
+
+// handlePatchConfig 按 JSON Merge Patch（RFC 7386）修改用户配置层，未出现的字段保持不变
+// 值为 null 时删除用户的设置，恢复为团队、系统配置或默认值
+func (s *Service) handlePatchConfig(c *gin.Context) {
+	var patch map[string]interface{}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		respondConfigError(c, newServiceError(ErrCodeInvalidRequest, "Invalid request: "+err.Error(), err))
+		return
+	}
+
+	config, err := s.live.update(func(current *layeredConfig) (map[string]interface{}, error) {
+		user := map[string]interface{}{}
+		if layer := current.userLayer(); layer != nil {
+			user = cloneMap(layer.raw)
+		}
+		mergePatch(user, patch)
+		user["version"] = CurrentConfigVersion
+		return user, nil
+	})
+	if err != nil {
+		respondConfigError(c, err)
+		return
+	}
+	c.JSON(200, ConfigUpdateResponse{Status: "ok", Config: config})
+}
+
+// configFromMap 按配置文件的规则解析请求中的配置，旧版本同样会被迁移
+func configFromMap(raw map[string]interface{}) (*Config, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := parseConfig(data)
+	return config, err
+}
+
+// respondConfigError 返回配置错误，errors 中列出每个字段的问题
+func respondConfigError(c *gin.Context, err error) {
+	se := asServiceError(err)
+	c.JSON(se.Code.HTTPStatus(), ConfigErrorResponse{
+		Status:  "error",
+		Code:    se.Code,
+		Message: se.Message,
+		Error:   se.Message,
+		Errors:  fieldErrors(err),
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// ReuseConfig 控制是否复用已运行的编辑器实例
+type ReuseConfig struct {
+	Disabled    bool   `json:"disabled,omitempty"`    // 总是启动新实例
+	NvimSocket  string `json:"nvimSocket,omitempty"`  // Neovim 的 --listen 地址，为空时查找默认位置
+	EmacsSocket string `json:"emacsSocket,omitempty"` // emacsclient -s 使用的服务名或 socket 路径
+}
+
+const (
+	reuseNvim   = "nvim"
+	reuseEmacs  = "emacs"
+	reuseVSCode = "vscode"
+
+	LaunchReused = "reused" // 交给已运行的实例打开
+	LaunchNew    = "new"    // 启动了新进程
+)
+
+// LaunchResult 描述 IDE 是如何被打开的
+type LaunchResult struct {
+	Mode   string `json:"mode"`             // reused 或 new
+	Detail string `json:"detail,omitempty"` // 使用的 socket，或未能复用的原因
+}
+
+// tryReuseInstance 尝试通过远程控制协议让已运行的编辑器打开文件
+// 返回 nil 表示没有可复用的实例，调用方应启动新进程；reason 说明未复用的原因
+func tryReuseInstance(ide ideConfig, target OpenTarget, config *ReuseConfig) (result *LaunchResult, reason string) {
+	if config == nil {
+		config = &ReuseConfig{}
+	}
+	if config.Disabled {
+		return nil, ""
+	}
+
+	switch ide.reuse {
+	case reuseNvim:
+		socket := config.NvimSocket
+		if socket == "" {
+			socket = findNvimSocket()
+		}
+		if socket == "" {
+			return nil, "no running Neovim server found"
+		}
+		if err := nvimRemoteOpen(socket, target); err != nil {
+			return nil, fmt.Sprintf("nvim --server %s: %v", socket, err)
+		}
+		return &LaunchResult{Mode: LaunchReused, Detail: socket}, ""
+
+	case reuseEmacs:
+		args := []string{"-n"}
+		if config.EmacsSocket != "" {
+			args = append(args, "-s", expandPath(config.EmacsSocket))
+		}
+		if target.Line > 0 {
+			args = append(args, "+"+strconv.Itoa(target.Line))
+		}
+		args = append(args, target.Path)
+		if output, err := exec.Command("emacsclient", args...).CombinedOutput(); err != nil {
+			return nil, fmt.Sprintf("emacsclient: %s", sanitizeOutput(string(output)))
+		}
+		return &LaunchResult{Mode: LaunchReused, Detail: "emacsclient"}, ""
+	}
+	return nil, ""
+}
+
+// nvimRemoteOpen 在已运行的 Neovim 中新建标签页打开文件并跳转到行
+func nvimRemoteOpen(socket string, target OpenTarget) error {
+	cmd := exec.Command("nvim", "--server", socket, "--remote-tab", target.Path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, sanitizeOutput(string(output)))
+	}
+	if target.Line > 0 {
+		keys := fmt.Sprintf(`<C-\><C-N>:%d<CR>`, target.Line)
+		if err := exec.Command("nvim", "--server", socket, "--remote-send", keys).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findNvimSocket 查找最近启动的 Neovim 默认 socket
+// Neovim 0.9+ 默认在 $XDG_RUNTIME_DIR（macOS 为 $TMPDIR/nvim.$USER/*）下创建 nvim.<pid>.0
+func findNvimSocket() string {
+	if addr := os.Getenv("NVIM_LISTEN_ADDRESS"); addr != "" {
+		return addr
+	}
+
+	var patterns []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		patterns = append(patterns, filepath.Join(dir, "nvim.*.0"))
+	}
+	patterns = append(patterns, filepath.Join(os.TempDir(), "nvim."+os.Getenv("USER"), "*", "nvim.*.0"))
+
+	var sockets []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		sockets = append(sockets, matches...)
+	}
+	if len(sockets) == 0 {
+		return ""
+	}
+
+	// 选择最近创建的实例
+	sort.Slice(sockets, func(i, j int) bool {
+		fi, erri := os.Stat(sockets[i])
+		fj, errj := os.Stat(sockets[j])
+		if erri != nil || errj != nil {
+			return erri == nil
+		}
+		return fi.ModTime().After(fj.ModTime())
+	})
+	return sockets[0]
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveResponse 描述 /open 对同一请求会执行的操作
+type ResolveResponse struct {
+	Status       string         `json:"status"`
+	URL          *GitHubURLInfo `json:"url"`                // 解析出的 URL 信息
+	Mapping      *PathMapping   `json:"mapping,omitempty"`  // 匹配的路径映射规则，为空表示使用默认 cacheDir
+	RepoPath     string         `json:"repoPath"`           // 本地仓库路径
+	Exists       bool           `json:"exists"`             // 本地仓库是否已存在
+	Ref          string         `json:"ref,omitempty"`      // 要 checkout 的分支、tag 或 PR 分支
+	SHA          string         `json:"sha,omitempty"`      // ref 在本地解析出的 commit，仓库不存在或尚未获取时为空
+	RefError     string         `json:"refError,omitempty"` // ref 无法在本地解析的原因
+	TargetPath   string         `json:"targetPath"`         // 要打开的文件或目录
+	Line         int            `json:"line,omitempty"`
+	IDE          string         `json:"ide"`
+	FallbackFrom string         `json:"fallbackFrom,omitempty"` // 未安装而被替换的 IDE
+	IDERule      *IDERule       `json:"ideRule,omitempty"`      // 选择 IDE 时命中的规则
+	Language     string         `json:"language,omitempty"`     // 匹配规则时检测到的仓库语言
+	Command      []string       `json:"command,omitempty"`      // OpenInIDE 将执行的命令行
+	Dir          string         `json:"dir,omitempty"`          // 命令的工作目录
+	Hooks        []*Hook        `json:"hooks,omitempty"`        // 将要执行的 hook；仓库不存在时无法判断 markers
+	Target       string         `json:"target"`                 // 目标模式：local、devcontainer 或 ssh
+}
+
+// handleResolve 解释 /open 会做什么，不会克隆、获取或启动 IDE
+func (s *Service) handleResolve(c *gin.Context) {
+	var req OpenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, newServiceError(ErrCodeInvalidRequest, fmt.Sprintf("Invalid request: %v", err), err))
+		return
+	}
+
+	info, err := ParseGitHubURL(req.URL)
+	if err != nil {
+		respondError(c, fmt.Errorf("Invalid GitHub URL: %w", err))
+		return
+	}
+
+	mode, err := s.requestedTargetMode(req.Target, info)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	host, err := s.prepareHost(mode, info)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	repoPath := host.repoPath
+	resp := ResolveResponse{
+		Status:   "ok",
+		URL:      info,
+		RepoPath: repoPath,
+		Exists:   host.exists(),
+	}
+	if mode != TargetSSH {
+		resp.Mapping = s.paths.resolve(info).Mapping
+	}
+
+	switch info.Type {
+	case URLTypeRepo:
+		resp.Ref = info.Branch
+	case URLTypePR:
+		resp.Ref = fmt.Sprintf("pr-%d", info.PRNumber)
+	}
+	if resp.Ref != "" && resp.Exists {
+		name, sha, err := host.git.ResolveRef(repoPath, resp.Ref)
+		if err != nil {
+			resp.RefError = err.Error()
+		} else {
+			resp.Ref, resp.SHA = name, sha
+		}
+	}
+
+	target, choice := s.resolveTarget(&req, info, repoPath)
+	target.Mode = mode
+	if resp.Exists {
+		if err := s.applyDevContainer(req.Target, &target, choice.IDE); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	resp.Target = target.Mode
+	resp.TargetPath, resp.Line = target.Path, target.Line
+	resp.IDE, resp.FallbackFrom, resp.IDERule, resp.Language = choice.IDE, choice.FallbackFrom, choice.Rule, choice.Language
+	cmd, err := BuildIDECommand(choice.IDE, target, s.config)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	resp.Command, resp.Dir = cmd.Args, cmd.Dir
+
+	stages := []string{HookBeforeLaunch}
+	switch {
+	case !resp.Exists:
+		stages = []string{HookAfterClone, HookAfterCheckout, HookBeforeLaunch}
+	case resp.Ref != "":
+		stages = []string{HookAfterCheckout, HookBeforeLaunch}
+	}
+	if mode != TargetSSH {
+		resp.Hooks = s.matchHooks(info, repoPath, stages)
+	}
+
+	c.JSON(200, resp)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDERule 定义请求未指定 IDE 时的选择规则
+// 规则按顺序匹配，设置的条件全部满足时使用该规则的 IDE
+type IDERule struct {
+	Name       string   `json:"name,omitempty"`       // 规则名称，便于在 /resolve 中识别
+	Repo       string   `json:"repo,omitempty"`       // owner/repo 模式，支持通配符，如 "myorg/*"；只写 owner 表示其所有仓库
+	Extensions []string `json:"extensions,omitempty"` // 打开文件的扩展名，如 ".go"
+	Language   string   `json:"language,omitempty"`   // 仓库主要语言，如 "go"、"python"（不区分大小写）
+	IDE        string   `json:"ide"`
+}
+
+// ideChoice 记录 IDE 是如何选出的
+type ideChoice struct {
+	IDE          string
+	FallbackFrom string   // 未安装而被替换的 IDE
+	Rule         *IDERule // 命中的规则，为空表示来自请求或 defaultIDE
+	Language     string   // 规则匹配时检测到的仓库语言
+}
+
+// languageMarkers 是用于识别仓库主要语言的标记文件，按顺序检查
+var languageMarkers = []struct {
+	file     string
+	language string
+}{
+	{"go.mod", "go"},
+	{"Cargo.toml", "rust"},
+	{"pyproject.toml", "python"},
+	{"setup.py", "python"},
+	{"requirements.txt", "python"},
+	{"tsconfig.json", "typescript"},
+	{"package.json", "javascript"},
+	{"pom.xml", "java"},
+	{"build.gradle", "java"},
+	{"build.gradle.kts", "kotlin"},
+	{"Gemfile", "ruby"},
+	{"composer.json", "php"},
+	{"mix.exs", "elixir"},
+	{"CMakeLists.txt", "c++"},
+}
+
+// detectLanguageFromMarkers 根据仓库根目录的标记文件识别语言
+func detectLanguageFromMarkers(repoPath string) string {
+	for _, m := range languageMarkers {
+		if _, err := os.Stat(filepath.Join(repoPath, m.file)); err == nil {
+			return m.language
+		}
+	}
+	return ""
+}
+
+const (
+	languageLookupTimeout = 3 * time.Second
+	languageCacheTTL      = 24 * time.Hour
+	languageRetryAfter    = 10 * time.Minute // 查询失败（如超出速率限制）后多久再重试
+)
+
+// cachedLanguage 是 GitHub languages API 查询结果的缓存
+type cachedLanguage struct {
+	language string
+	expires  time.Time
+}
+
+// repoLanguages 按 owner/repo 缓存 GitHub 返回的主要语言，避免每次打开都调用 API
+var repoLanguages sync.Map
+
+// repoLanguage 返回仓库的主要语言：优先使用本地标记文件，其次查询 GitHub languages API
+func (s *Service) repoLanguage(info *GitHubURLInfo, repoPath string) string {
+	if lang := detectLanguageFromMarkers(repoPath); lang != "" {
+		return lang
+	}
+
+	key := info.Owner + "/" + info.Repo
+	if cached, ok := repoLanguages.Load(key); ok && time.Now().Before(cached.(cachedLanguage).expires) {
+		return cached.(cachedLanguage).language
+	}
+	ctx, cancel := context.WithTimeout(jobsCtx, languageLookupTimeout)
+	defer cancel()
+	lang, err := s.ghClient.GetPrimaryLanguage(ctx, info.Owner, info.Repo)
+	if err != nil {
+		s.log.Debug("Detecting repository language failed", "repo", key, "error", err)
+		repoLanguages.Store(key, cachedLanguage{expires: time.Now().Add(languageRetryAfter)})
+		return ""
+	}
+	lang = strings.ToLower(lang)
+	repoLanguages.Store(key, cachedLanguage{language: lang, expires: time.Now().Add(languageCacheTTL)})
+	return lang
+}
+
+// matchIDERule 返回第一个匹配的规则及检测到的语言
+func (s *Service) matchIDERule(info *GitHubURLInfo, target OpenTarget) (*IDERule, string) {
+	var language string
+	languageDetected := false
+
+	for i := range s.config.IDERules {
+		rule := &s.config.IDERules[i]
+		if rule.Repo != "" && !matchRepoPattern(rule.Repo, info.Owner, info.Repo) {
+			continue
+		}
+		if len(rule.Extensions) > 0 && !matchExtension(rule.Extensions, target.Path) {
+			continue
+		}
+		if rule.Language != "" {
+			// 语言检测可能需要访问 GitHub API，只在需要时检测一次
+			if !languageDetected {
+				language = s.repoLanguage(info, target.RepoPath)
+				languageDetected = true
+			}
+			if !strings.EqualFold(rule.Language, language) {
+				continue
+			}
+		}
+		return rule, language
+	}
+	return nil, language
+}
+
+// matchRepoPattern 判断 owner/repo 是否匹配规则中的模式
+func matchRepoPattern(pattern, owner, repo string) bool {
+	if !strings.Contains(pattern, "/") {
+		pattern += "/*"
+	}
+	ok, _ := path.Match(pattern, owner+"/"+repo)
+	return ok
+}
+
+// matchExtension 判断文件扩展名是否在列表中
+func matchExtension(extensions []string, file string) bool {
+	ext := filepath.Ext(file)
+	if ext == "" {
+		return false
+	}
+	for _, e := range extensions {
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 检查规则是否有效
+func (r IDERule) validate(customIDEs map[string]CustomIDE) error {
+	if r.IDE == "" {
+		return fmt.Errorf("ide is required")
+	}
+	if _, ok := lookupIDE(r.IDE, customIDEs); !ok {
+		return fmt.Errorf("unknown IDE %s", r.IDE)
+	}
+	if r.Repo == "" && len(r.Extensions) == 0 && r.Language == "" {
+		return fmt.Errorf("at least one of repo, extensions or language is required")
+	}
+	if r.Repo != "" {
+		if _, err := path.Match(r.Repo, ""); err != nil {
+			return fmt.Errorf("repo: invalid pattern %q", r.Repo)
+		}
+	}
+	return nil
+}
+
+// ruleLabel 返回规则用于日志的描述
+func ruleLabel(rule *IDERule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	var conds []string
+	if rule.Repo != "" {
+		conds = append(conds, "repo="+rule.Repo)
+	}
+	if len(rule.Extensions) > 0 {
+		conds = append(conds, "extensions="+strings.Join(rule.Extensions, ","))
+	}
+	if rule.Language != "" {
+		conds = append(conds, "language="+rule.Language)
+	}
+	return strings.Join(conds, " ") + " -> " + rule.IDE
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdListenFDsStart 是 systemd socket activation 传入的第一个文件描述符
+const sdListenFDsStart = 3
+
+// systemd 通过环境变量传入的通知套接字和 watchdog 间隔，由 takeSystemdEnv 读取
+var (
+	notifySocket     string
+	watchdogInterval time.Duration
+)
+
+// takeSystemdEnv 读取 systemd 传入的环境变量并从进程环境中删除，避免被 git、IDE 等子进程继承
+func takeSystemdEnv() {
+	pid := strconv.Itoa(os.Getpid())
+	notifySocket = os.Getenv("NOTIFY_SOCKET")
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		if p := os.Getenv("WATCHDOG_PID"); p == "" || p == pid {
+			watchdogInterval = time.Duration(usec) * time.Microsecond
+		}
+	}
+	for _, name := range []string{"NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID"} {
+		os.Unsetenv(name)
+	}
+}
+
+// sdNotify 向 systemd 发送状态通知，如 "READY=1"；不是由 systemd 以 Type=notify 启动时不做任何事
+func sdNotify(state string) {
+	if notifySocket == "" {
+		return
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: notifySocket, Net: "unixgram"})
+	if err != nil {
+		slog.Debug("sd_notify failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		slog.Debug("sd_notify failed", "error", err)
+	}
+}
+
+// startWatchdog 在 unit 配置了 WatchdogSec 时按一半的间隔发送 WATCHDOG=1
+// 每次发送前获取当前的 Service，配置锁死锁时停止发送，由 systemd 重启服务
+func startWatchdog(live *liveService) (stop func()) {
+	if watchdogInterval == 0 {
+		return func() {}
+	}
+	ticker := time.NewTicker(watchdogInterval / 2)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				live.get()
+				sdNotify("WATCHDOG=1")
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// systemdListeners 返回 systemd socket activation 传入的监听套接字，不是由 socket 启动时返回 nil
+func systemdListeners() ([]net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		os.Unsetenv(name)
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for fd := sdListenFDsStart; fd < sdListenFDsStart+n; fd++ {
+		// FileListener 复制了描述符（带 close-on-exec），原描述符可以关闭，子进程不会继承
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("listen-fd-%d", fd))
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket activation: fd %d: %w", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
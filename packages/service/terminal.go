@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// TerminalConfig 定义终端编辑器（nvim、vim、helix 等）在哪个终端中打开
+type TerminalConfig struct {
+	// Emulator 为终端名称，如 "kitty"、"gnome-terminal"、"tmux"、"macos-terminal"，为空时自动检测
+	Emulator string `json:"emulator,omitempty"`
+	// Command 为自定义命令模板，设置后忽略 Emulator
+	// $CMD 展开为编辑器命令的各个参数，$CMDLINE 展开为经过 shell 转义的单个字符串
+	Command []string `json:"command,omitempty"`
+	// TmuxSession 为 tmux 模式使用的会话名，默认 "github-browser"
+	TmuxSession string `json:"tmuxSession,omitempty"`
+}
+
+const (
+	TerminalTmux       = "tmux"
+	TerminalMacOS      = "macos-terminal"
+	defaultTmuxSession = "github-browser"
+	cmdPlaceholder     = "$CMD"
+	cmdlinePlaceholder = "$CMDLINE"
+)
+
+// terminalTemplates 是内置终端的命令模板
+var terminalTemplates = map[string][]string{
+	"gnome-terminal": {"gnome-terminal", "--", "$CMD"},
+	"kitty":          {"kitty", "$CMD"},
+	"alacritty":      {"alacritty", "-e", "$CMD"},
+	"wezterm":        {"wezterm", "start", "--", "$CMD"},
+	"foot":           {"foot", "$CMD"},
+	"konsole":        {"konsole", "-e", "$CMD"},
+	"xterm":          {"xterm", "-e", "$CMD"},
+	TerminalMacOS:    {"osascript", "-e", `tell application "Terminal" to do script "$CMDLINE"`},
+}
+
+// terminalDetectOrder 是 Linux 上自动检测终端的顺序
+var terminalDetectOrder = []string{"kitty", "alacritty", "wezterm", "foot", "gnome-terminal", "konsole", "xterm", TerminalTmux}
+
+// detectTerminal 返回本机可用的终端
+func detectTerminal() (string, error) {
+	if runtime.GOOS == "darwin" {
+		return TerminalMacOS, nil
+	}
+	for _, name := range terminalDetectOrder {
+		if _, err := exec.LookPath(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", newServiceError(ErrCodeIDENotInstalled, "no terminal emulator found for terminal editor", nil)
+}
+
+// wrapInTerminal 将编辑器命令包装为在终端中运行的命令，dir 为终端的起始目录
+func wrapInTerminal(argv []string, dir string, config *TerminalConfig) (*exec.Cmd, error) {
+	if config == nil {
+		config = &TerminalConfig{}
+	}
+
+	template := config.Command
+	escape := func(s string) string { return s }
+	if len(template) == 0 {
+		emulator := config.Emulator
+		if emulator == "" {
+			var err error
+			if emulator, err = detectTerminal(); err != nil {
+				return nil, err
+			}
+		}
+
+		if emulator == TerminalTmux {
+			return tmuxCommand(argv, dir, config.TmuxSession), nil
+		}
+
+		var ok bool
+		if template, ok = terminalTemplates[emulator]; !ok {
+			return nil, fmt.Errorf("unsupported terminal emulator: %s", emulator)
+		}
+		if emulator == TerminalMacOS {
+			// AppleScript 中执行的是 shell 命令，需要先 cd 到仓库目录
+			argv = append([]string{"cd", dir, "&&"}, argv...)
+			escape = appleScriptEscape
+		}
+	}
+
+	args := expandTerminalTemplate(template[1:], argv, escape)
+	cmd := exec.Command(expandPath(template[0]), args...)
+	cmd.Dir = dir
+	return cmd, nil
+}
+
+// expandTerminalTemplate 展开 $CMD 和 $CMDLINE 占位符，escape 用于嵌入其他语言的字符串时转义 $CMDLINE
+func expandTerminalTemplate(template, argv []string, escape func(string) string) []string {
+	var args []string
+	for _, arg := range template {
+		switch {
+		case arg == cmdPlaceholder:
+			args = append(args, argv...)
+		case strings.Contains(arg, cmdlinePlaceholder):
+			args = append(args, strings.ReplaceAll(arg, cmdlinePlaceholder, escape(shellJoin(argv))))
+		default:
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// tmuxScript 在启动时检查会话：已存在时新建窗口，否则创建后台会话
+// 参数依次为会话名、工作目录和编辑器命令
+const tmuxScript = `s=$1 d=$2; shift 2
+if tmux has-session -t "$s" 2>/dev/null; then exec tmux new-window -t "$s" -c "$d" -- "$@"; fi
+exec tmux new-session -d -s "$s" -c "$d" -- "$@"`
+
+// tmuxCommand 在已有的 tmux 会话中新建窗口，会话不存在时创建一个后台会话
+// 会话是否存在在命令执行时才检查，构造命令（如 /resolve）没有副作用
+func tmuxCommand(argv []string, dir, session string) *exec.Cmd {
+	if session == "" {
+		session = defaultTmuxSession
+	}
+	return exec.Command("sh", append([]string{"-c", tmuxScript, "tmux", session, dir}, argv...)...)
+}
+
+// shellJoin 将参数转义拼接为 shell 命令行，"&&" 等操作符保持原样
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg == "&&" || isShellSafe(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func isShellSafe(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:+=,@", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// appleScriptEscape 转义 AppleScript 字符串中的反斜杠和双引号
+func appleScriptEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -45,4 +45,9 @@ echo "6️⃣  Getting config..."
 curl -s $BASE_URL/config | jq .
 echo ""
 
+# OpenAPI 文档
+echo "7️⃣  Getting OpenAPI document..."
+curl -s $BASE_URL/openapi.json | jq '.paths | keys'
+echo ""
+
 echo "✅ Tests complete!"
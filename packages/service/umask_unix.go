@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// withUmask 在 fn 执行期间使用 mask 作为进程的 umask，用于创建一开始就只有当前用户可以访问的文件
+// umask 对整个进程生效，只在启动阶段调用
+func withUmask(mask int, fn func() error) error {
+	old := syscall.Umask(mask)
+	defer syscall.Umask(old)
+	return fn()
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+// withUmask 在 Windows 上没有 umask，直接执行 fn
+func withUmask(mask int, fn func() error) error {
+	return fn()
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	SchemeGitHubBrowser = "github-browser"  // github-browser://open?url=...&ide=...
+	SchemeGitHubDesktop = "x-github-client" // GitHub Desktop 使用的 scheme，可选注册
+
+	desktopFileName = "github-browser.desktop"
+)
+
+// URLHandlerConfig 控制 github-browser:// 链接可以打开哪些地址
+type URLHandlerConfig struct {
+	// AllowedHosts 为链接中 url 参数允许的主机，默认只允许 github.com
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+}
+
+// allowedHosts 返回允许的主机列表
+func (c *URLHandlerConfig) allowedHosts() []string {
+	if c == nil || len(c.AllowedHosts) == 0 {
+		return []string{"github.com"}
+	}
+	return c.AllowedHosts
+}
+
+// validate 检查配置是否有效
+func (c *URLHandlerConfig) validate() error {
+	for i, host := range c.AllowedHosts {
+		if host == "" || strings.ContainsAny(host, "/:@ ") {
+			return fmt.Errorf("allowedHosts[%d]: invalid host %q", i, host)
+		}
+	}
+	return nil
+}
+
+// ideNamePattern 限制链接中可以指定的 IDE 名称字符
+var ideNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// parseSchemeURL 将 github-browser:// 或 x-github-client:// 链接转换为 /open 请求
+// 链接来自浏览器、聊天工具等不受信任的来源，只接受允许的主机上的 https 地址
+func parseSchemeURL(raw string, config *URLHandlerConfig) (*OpenRequest, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidURL, fmt.Sprintf("invalid link: %v", err), err)
+	}
+
+	var req OpenRequest
+	query := u.Query()
+	switch u.Scheme {
+	case SchemeGitHubBrowser:
+		if u.Host != "open" {
+			return nil, newServiceError(ErrCodeInvalidURL, fmt.Sprintf("unsupported action: %s", u.Host), nil)
+		}
+		req.URL = query.Get("url")
+		req.IDE = query.Get("ide")
+		req.FilePath = query.Get("file")
+		req.Target = query.Get("target")
+		if req.Line, err = optionalInt(query.Get("line")); err != nil {
+			return nil, newServiceError(ErrCodeInvalidURL, "invalid line", err)
+		}
+		if req.Column, err = optionalInt(query.Get("column")); err != nil {
+			return nil, newServiceError(ErrCodeInvalidURL, "invalid column", err)
+		}
+
+	case SchemeGitHubDesktop:
+		// x-github-client://openRepo/https://github.com/owner/repo?branch=main&filepath=README.md
+		if u.Host != "openRepo" {
+			return nil, newServiceError(ErrCodeInvalidURL, fmt.Sprintf("unsupported action: %s", u.Host), nil)
+		}
+		repoURL := strings.TrimPrefix(u.Path, "/")
+		if branch := query.Get("branch"); branch != "" {
+			if file := query.Get("filepath"); file != "" {
+				repoURL += "/blob/" + branch + "/" + file
+			} else {
+				repoURL += "/tree/" + branch
+			}
+		}
+		req.URL = repoURL
+
+	default:
+		return nil, newServiceError(ErrCodeInvalidURL, fmt.Sprintf("unsupported scheme: %s", u.Scheme), nil)
+	}
+
+	if err := checkAllowedURL(req.URL, config.allowedHosts()); err != nil {
+		return nil, err
+	}
+	if req.IDE != "" && !ideNamePattern.MatchString(req.IDE) {
+		return nil, newServiceError(ErrCodeInvalidURL, fmt.Sprintf("invalid ide: %q", req.IDE), nil)
+	}
+	if req.FilePath != "" && !filepath.IsLocal(req.FilePath) {
+		return nil, newServiceError(ErrCodeInvalidURL, fmt.Sprintf("invalid file: %q", req.FilePath), nil)
+	}
+	return &req, nil
+}
+
+// checkAllowedURL 检查链接中的仓库地址是允许主机上的 https 地址
+func checkAllowedURL(raw string, hosts []string) error {
+	if raw == "" {
+		return newServiceError(ErrCodeInvalidURL, "missing url parameter", nil)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return newServiceError(ErrCodeInvalidURL, fmt.Sprintf("url must be an https URL: %s", raw), err)
+	}
+	for _, host := range hosts {
+		if strings.EqualFold(u.Host, host) {
+			return nil
+		}
+	}
+	return newServiceError(ErrCodeInvalidURL, fmt.Sprintf("host %s is not in urlHandler.allowedHosts", u.Host), nil)
+}
+
+func optionalInt(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err == nil && n < 0 {
+		err = fmt.Errorf("negative value %d", n)
+	}
+	return n, err
+}
+
+// cliHandleURL 处理桌面环境传入的链接，失败时通过桌面通知提示
+func cliHandleURL(opts cliOptions, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: handle-url <github-browser://open?url=...>")
+		return exitUsage
+	}
+
+	req, err := parseSchemeURL(args[0], loadConfigOrDefault().URLHandler)
+	if err != nil {
+		se := asServiceError(err)
+		fmt.Fprintf(os.Stderr, "❌ %s\n", se.Message)
+		notify("GitHub Browser", se.Message)
+		return se.Code.ExitCode()
+	}
+
+	code := request(opts, "POST", "/open", req, func(resp map[string]interface{}) {
+		fmt.Printf("✅ Opened %s in %s\n", resp["path"], resp["ide"])
+	})
+	if code != exitOK {
+		notify("GitHub Browser", "Failed to open "+req.URL)
+	}
+	return code
+}
+
+// notify 尽力发送桌面通知，由链接启动时没有终端可以显示错误
+func notify(title, message string) {
+	if runtime.GOOS == "linux" {
+		exec.Command("notify-send", "--app-name=github-browser", title, message).Run()
+	}
+}
+
+func cliURLHandler(args []string) int {
+	if runtime.GOOS != "linux" {
+		fmt.Fprintln(os.Stderr, "❌ url-handler is only supported on Linux")
+		return exitError
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: url-handler install [--x-github-client] | uninstall")
+		return exitUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		desktopClient := len(args) > 1 && args[1] == "--x-github-client"
+		err = installURLHandler(desktopClient)
+	case "uninstall":
+		err = uninstallURLHandler()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown url-handler command: %s\n", args[0])
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// applicationsDir 返回用户的 XDG applications 目录
+func applicationsDir() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "applications")
+}
+
+// installURLHandler 写入 .desktop 文件并将其设为 scheme 的默认处理程序
+func installURLHandler(desktopClient bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+
+	schemes := []string{SchemeGitHubBrowser}
+	if desktopClient {
+		schemes = append(schemes, SchemeGitHubDesktop)
+	}
+	var mimeTypes []string
+	for _, scheme := range schemes {
+		mimeTypes = append(mimeTypes, "x-scheme-handler/"+scheme)
+	}
+
+	dir := applicationsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// Exec 中的路径按 desktop entry 规范加引号，%u 为链接
+	content := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=GitHub Browser
+Comment=Open GitHub links in a local IDE
+Exec="%s" handle-url %%u
+Terminal=false
+NoDisplay=true
+MimeType=%s;
+`, strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace(executable), strings.Join(mimeTypes, ";"))
+
+	file := filepath.Join(dir, desktopFileName)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("📝 Wrote %s\n", file)
+
+	for _, mimeType := range mimeTypes {
+		if output, err := exec.Command("xdg-mime", "default", desktopFileName, mimeType).CombinedOutput(); err != nil {
+			return fmt.Errorf("xdg-mime default %s: %v %s", mimeType, err, strings.TrimSpace(string(output)))
+		}
+		fmt.Printf("🔗 Registered %s\n", mimeType)
+	}
+	// 部分桌面环境依赖 mimeinfo.cache，命令不存在时忽略
+	exec.Command("update-desktop-database", dir).Run()
+	return nil
+}
+
+// uninstallURLHandler 删除 .desktop 文件和 mimeapps.list 中的关联
+func uninstallURLHandler() error {
+	file := filepath.Join(applicationsDir(), desktopFileName)
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fmt.Printf("🗑️  Removed %s\n", file)
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	if err := removeMimeAssociations(filepath.Join(configHome, "mimeapps.list")); err != nil {
+		return err
+	}
+	exec.Command("update-desktop-database", applicationsDir()).Run()
+	return nil
+}
+
+// removeMimeAssociations 从 mimeapps.list 中删除指向本程序 .desktop 文件的关联
+// xdg-mime 没有取消关联的命令，只能直接编辑文件
+func removeMimeAssociations(mimeapps string) error {
+	data, err := os.ReadFile(mimeapps)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.HasPrefix(key, "x-scheme-handler/") {
+			var kept []string
+			for _, app := range strings.Split(value, ";") {
+				if app != "" && app != desktopFileName {
+					kept = append(kept, app)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			line = key + "=" + strings.Join(kept, ";") + ";"
+		}
+		lines = append(lines, line)
+	}
+	return os.WriteFile(mimeapps, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}